@@ -0,0 +1,109 @@
+package storekit
+
+import (
+	"context"
+	"sync"
+)
+
+// BatchItem is a receipt request to verify in a batch, identified by an ID of
+// the caller's choosing, e.g. the primary key of the stored receipt.
+type BatchItem struct {
+	ID      string
+	Request *ReceiptRequest
+}
+
+// BatchResult is the outcome of verifying a BatchItem.
+type BatchResult struct {
+	// ID is the ID of the BatchItem.
+	ID string
+
+	// Result is the result of the verification, as returned by the verifier.
+	Result *VerifyResult
+
+	// Err is the error of the verification, if any.
+	Err error
+}
+
+// BatchOptions control how VerifyBatch runs.
+type BatchOptions struct {
+	// Concurrency is the maximum number of verifications in flight. Defaults to
+	// 8.
+	Concurrency int
+
+	// RatePerSecond limits how many verifications are started per second. Zero
+	// means no limit.
+	RatePerSecond float64
+
+	// Burst is the number of verifications that may be started at once before
+	// RatePerSecond applies. Defaults to 1.
+	Burst int
+}
+
+// VerifyBatch verifies the items received from items with verifier, and sends
+// one BatchResult per item on the returned channel, in completion order. A
+// failed verification is reported in its BatchResult and doesn't affect the
+// other items.
+//
+// The returned channel is closed once items is closed and all its items are
+// verified, or once ctx is done. In the latter case, items not yet received are
+// left in items, so producers should stop sending when ctx is done too. The
+// returned channel must be drained until closed.
+func VerifyBatch(ctx context.Context, verifier Verifier, items <-chan BatchItem, opts BatchOptions) <-chan BatchResult {
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 8
+	}
+
+	var limiter *tokenBucket
+	if opts.RatePerSecond > 0 {
+		limiter = newTokenBucket(opts.RatePerSecond, opts.Burst)
+	}
+
+	results := make(chan BatchResult, concurrency)
+
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer wg.Done()
+
+			for {
+				var item BatchItem
+				var ok bool
+				select {
+				case <-ctx.Done():
+					return
+				case item, ok = <-items:
+					if !ok {
+						return
+					}
+				}
+
+				results <- verifyBatchItem(ctx, verifier, limiter, item)
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
+
+func verifyBatchItem(ctx context.Context, verifier Verifier, limiter *tokenBucket, item BatchItem) BatchResult {
+	// Caught here so that no verifier panics and no rate limit token is spent.
+	if item.Request == nil {
+		return BatchResult{ID: item.ID, Result: &VerifyResult{SharedSecretIndex: -1}, Err: ErrNilRequest}
+	}
+
+	if limiter != nil {
+		if err := limiter.wait(ctx); err != nil {
+			return BatchResult{ID: item.ID, Result: &VerifyResult{}, Err: err}
+		}
+	}
+
+	result, err := verifier.Verify(ctx, item.Request)
+	return BatchResult{ID: item.ID, Result: result, Err: err}
+}
@@ -0,0 +1,171 @@
+package storekit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// newBatchAppStore returns a fake App Store that fails receipts starting with
+// "malformed" with status 21002 and those starting with "down" with HTTP 503,
+// and reports the most requests it had in flight at once.
+func newBatchAppStore(t *testing.T) (*httptest.Server, func() int) {
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}()
+		time.Sleep(5 * time.Millisecond)
+
+		body, _ := ioutil.ReadAll(r.Body)
+		var req ReceiptRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+
+		switch receipt := string(req.ReceiptData); {
+		case strings.HasPrefix(receipt, "malformed"):
+			_ = json.NewEncoder(w).Encode(ReceiptResponse{Status: ReceiptResponseStatusDataMalformed})
+		case strings.HasPrefix(receipt, "down"):
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			_ = json.NewEncoder(w).Encode(ReceiptResponse{Status: ReceiptResponseStatusOK})
+		}
+	}))
+	t.Cleanup(s.Close)
+
+	return s, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return maxInFlight
+	}
+}
+
+func TestVerifyBatchReportsEachItem(t *testing.T) {
+	store, maxInFlight := newBatchAppStore(t)
+	c, err := NewClient(WithProductionURL(store.URL), WithRetryPolicy(NoRetry))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Receipts of the items, by ID.
+	want := map[string]string{}
+	for i := 0; i < 30; i++ {
+		want[fmt.Sprint(i)] = []string{"ok", "malformed", "ok", "down", "ok"}[i%5]
+	}
+
+	items := make(chan BatchItem)
+	go func() {
+		defer close(items)
+		for id, receipt := range want {
+			items <- BatchItem{ID: id, Request: &ReceiptRequest{ReceiptData: []byte(receipt)}}
+		}
+	}()
+
+	got := map[string]bool{}
+	for r := range VerifyBatch(context.Background(), c, items, BatchOptions{Concurrency: 3}) {
+		if got[r.ID] {
+			t.Errorf("item %s reported twice", r.ID)
+		}
+		got[r.ID] = true
+
+		var statusErr *StatusError
+		var httpErr *HTTPStatusError
+		switch want[r.ID] {
+		case "ok":
+			if r.Err != nil || r.Result.Response == nil {
+				t.Errorf("item %s: error = %v; want success", r.ID, r.Err)
+			}
+		case "malformed":
+			if !errors.As(r.Err, &statusErr) || statusErr.Status != ReceiptResponseStatusDataMalformed || r.Result.Response == nil {
+				t.Errorf("item %s: error = %v; want status 21002 with its response", r.ID, r.Err)
+			}
+		case "down":
+			if !errors.As(r.Err, &httpErr) || httpErr.StatusCode != http.StatusServiceUnavailable {
+				t.Errorf("item %s: error = %v; want HTTP 503", r.ID, r.Err)
+			}
+		}
+	}
+
+	if len(got) != len(want) {
+		t.Errorf("%d items reported; want %d", len(got), len(want))
+	}
+	if n := maxInFlight(); n > 3 {
+		t.Errorf("%d requests in flight at once; want at most 3", n)
+	}
+}
+
+func TestVerifyBatchStopsWhenContextDone(t *testing.T) {
+	store, _ := newBatchAppStore(t)
+	c, err := NewClient(WithProductionURL(store.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	items := make(chan BatchItem) // Never closed.
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case items <- BatchItem{ID: fmt.Sprint(i), Request: &ReceiptRequest{ReceiptData: []byte("ok")}}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	results := VerifyBatch(ctx, c, items, BatchOptions{Concurrency: 2, RatePerSecond: 100})
+	<-results
+	cancel()
+
+	closed := make(chan struct{})
+	go func() {
+		for range results {
+		}
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("results not closed once the context is done")
+	}
+}
+
+func TestVerifyBatchRejectsNilRequest(t *testing.T) {
+	verifier := VerifierFunc(func(ctx context.Context, req *ReceiptRequest) (*VerifyResult, error) {
+		if req == nil {
+			t.Error("verifier called with a nil request")
+		}
+		return &VerifyResult{SharedSecretIndex: -1}, nil
+	})
+
+	items := make(chan BatchItem, 2)
+	items <- BatchItem{ID: "nil"}
+	items <- BatchItem{ID: "ok", Request: &ReceiptRequest{ReceiptData: []byte("ok")}}
+	close(items)
+
+	for r := range VerifyBatch(context.Background(), verifier, items, BatchOptions{}) {
+		if want := map[string]error{"nil": ErrNilRequest}[r.ID]; r.Err != want || r.Result == nil {
+			t.Errorf("item %s: result = %v, error = %v; want a result and error %v", r.ID, r.Result, r.Err, want)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package storekit
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Cache stores entries for CachingVerifier: raw App Store response bodies along
+// with the environment that verified them. Keys are digests of receipt requests
+// and never contain receipt data or secrets. Implementations must be safe for
+// concurrent use.
+type Cache interface {
+	// Get returns the entry stored under key, if it has not expired.
+	Get(ctx context.Context, key string) ([]byte, bool)
+
+	// Set stores entry under key for ttl.
+	Set(ctx context.Context, key string, entry []byte, ttl time.Duration)
+}
+
+// CachingVerifier answers verifications from a Cache when it can, and from the
+// verifier it wraps otherwise. Only successful verifications are cached, for as
+// long as CacheTTL allows. The sandbox policy of the verifier is applied to
+// cached results too.
+type CachingVerifier struct {
+	verifier Verifier
+	cache    Cache
+	maxTTL   time.Duration
+}
+
+// NewCachingVerifier returns a CachingVerifier storing results of verifier in
+// cache for at most maxTTL.
+func NewCachingVerifier(verifier Verifier, cache Cache, maxTTL time.Duration) *CachingVerifier {
+	return &CachingVerifier{
+		verifier: verifier,
+		cache:    cache,
+		maxTTL:   maxTTL,
+	}
+}
+
+// Verify returns the cached result for req if there is one, with Cached set.
+// Cached results hold no attempts and no URL.
+func (v *CachingVerifier) Verify(ctx context.Context, req *ReceiptRequest) (*VerifyResult, error) {
+	if req == nil {
+		return &VerifyResult{SharedSecretIndex: -1}, ErrNilRequest
+	}
+
+	key := requestKey(req)
+
+	if entry, ok := v.cache.Get(ctx, key); ok {
+		if env, body, ok := parseCacheEntry(entry); ok {
+			_, resp, err := decodeResponse(bytes.NewReader(body), int64(len(body)), math.MaxInt64)
+			if err == nil && resp.Status == ReceiptResponseStatusOK {
+				result := &VerifyResult{
+					Body:              body,
+					Response:          resp,
+					Environment:       env,
+					Sandbox:           env == EnvironmentSandbox,
+					SharedSecretIndex: -1,
+					Cached:            true,
+				}
+				return result, checkSandboxPolicy(ctx, v.verifier, req, result)
+			}
+		}
+	}
+
+	// Whether the result is acceptable to this caller doesn't change whether it
+	// can be cached for others.
+	result, err := v.verifier.Verify(deferSandboxPolicy(ctx, v.verifier), req)
+	if err != nil || result.Response == nil || result.Body == nil {
+		return result, err
+	}
+
+	// The environment of the response body is optional, so results are only
+	// cached with the one that verified them.
+	if result.Environment == EnvironmentSandbox || result.Environment == EnvironmentProduction {
+		if ttl := CacheTTL(result.Response, time.Now(), v.maxTTL); ttl > 0 {
+			v.cache.Set(ctx, key, cacheEntry(result.Environment, result.Body), ttl)
+		}
+	}
+
+	return result, checkSandboxPolicy(ctx, v.verifier, req, result)
+}
+
+// cacheEntry returns the cache entry for body verified in env: the environment
+// on a line of its own, followed by the body.
+func cacheEntry(env Environment, body []byte) []byte {
+	entry := make([]byte, 0, len(env)+1+len(body))
+	entry = append(entry, env...)
+	entry = append(entry, '\n')
+	return append(entry, body...)
+}
+
+// parseCacheEntry returns the environment and body of a cache entry.
+func parseCacheEntry(entry []byte) (Environment, []byte, bool) {
+	i := bytes.IndexByte(entry, '\n')
+	if i < 0 {
+		return "", nil, false
+	}
+	env := Environment(entry[:i])
+	if env != EnvironmentSandbox && env != EnvironmentProduction {
+		return "", nil, false
+	}
+	return env, entry[i+1:], true
+}
+
+func (v *CachingVerifier) checkSandboxPolicy(ctx context.Context, req *ReceiptRequest, result *VerifyResult) error {
+	return checkSandboxPolicy(ctx, v.verifier, req, result)
+}
+
+// CacheTTL returns how long resp may be cached at now: until the earliest
+// upcoming subscription expiry or grace period end, so that renewals are
+// picked up, but no longer than maxTTL.
+func CacheTTL(resp *ReceiptResponse, now time.Time, maxTTL time.Duration) time.Duration {
+	ttl := maxTTL
+
+	consider := func(ts Timestamp) {
+		if ts.IsZero() || !ts.Time().After(now) {
+			return
+		}
+		if d := ts.Time().Sub(now); d < ttl {
+			ttl = d
+		}
+	}
+	for _, info := range resp.LatestReceiptInfo {
+		consider(info.ExpiresDateMs)
+	}
+	for _, info := range resp.PendingRenewalInfo {
+		consider(info.GracePeriodExpiresDateMs)
+	}
+
+	return ttl
+}
+
+// LRUCache is an in-memory Cache holding a bounded number of entries, evicting
+// the least recently used one when full.
+type LRUCache struct {
+	capacity int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // Front is most recently used.
+}
+
+type lruEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// NewLRUCache returns an LRUCache holding up to capacity entries.
+func NewLRUCache(capacity int) *LRUCache {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &LRUCache{
+		capacity: capacity,
+		entries:  map[string]*list.Element{},
+		order:    list.New(),
+	}
+}
+
+func (c *LRUCache) Get(ctx context.Context, key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*lruEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.remove(elem)
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+func (c *LRUCache) Set(ctx context.Context, key string, entry []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := time.Now().Add(ttl)
+
+	if elem, ok := c.entries[key]; ok {
+		e := elem.Value.(*lruEntry)
+		e.value = entry
+		e.expiresAt = expiresAt
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&lruEntry{
+		key:       key,
+		value:     entry,
+		expiresAt: expiresAt,
+	})
+	for c.order.Len() > c.capacity {
+		c.remove(c.order.Back())
+	}
+}
+
+// Len returns the number of entries in the cache, including expired ones not
+// evicted yet.
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *LRUCache) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*lruEntry).key)
+}
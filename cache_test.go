@@ -0,0 +1,116 @@
+package storekit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCacheTTL(t *testing.T) {
+	now := time.Date(2020, 6, 19, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) Timestamp { return TimestampOf(now.Add(d)) }
+
+	tests := []struct {
+		name string
+		resp ReceiptResponse
+		want time.Duration
+	}{
+		{
+			name: "no expiry",
+			want: time.Hour,
+		},
+		{
+			name: "earliest upcoming expiry",
+			resp: ReceiptResponse{LatestReceiptInfo: []LatestReceiptInfo{
+				{ExpiresDateMs: at(40 * time.Minute)},
+				{ExpiresDateMs: at(10 * time.Minute)},
+				{ExpiresDateMs: at(20 * time.Minute)},
+			}},
+			want: 10 * time.Minute,
+		},
+		{
+			name: "grace period end",
+			resp: ReceiptResponse{
+				LatestReceiptInfo:  []LatestReceiptInfo{{ExpiresDateMs: at(40 * time.Minute)}},
+				PendingRenewalInfo: []PendingRenewalInfo{{GracePeriodExpiresDateMs: at(5 * time.Minute)}},
+			},
+			want: 5 * time.Minute,
+		},
+		{
+			name: "past expiries",
+			resp: ReceiptResponse{
+				LatestReceiptInfo: []LatestReceiptInfo{
+					{ExpiresDateMs: at(-24 * time.Hour)},
+					{ExpiresDateMs: at(0)},
+					{ExpiresDateMs: at(30 * time.Minute)},
+				},
+				PendingRenewalInfo: []PendingRenewalInfo{{GracePeriodExpiresDateMs: at(-time.Minute)}},
+			},
+			want: 30 * time.Minute,
+		},
+		{
+			name: "capped",
+			resp: ReceiptResponse{LatestReceiptInfo: []LatestReceiptInfo{
+				{ExpiresDateMs: at(30 * 24 * time.Hour)},
+			}},
+			want: time.Hour,
+		},
+	}
+	for _, tt := range tests {
+		if got := CacheTTL(&tt.resp, now, time.Hour); got != tt.want {
+			t.Errorf("%s: CacheTTL() = %v; want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLRUCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRUCache(2)
+
+	c.Set(ctx, "a", []byte("1"), time.Hour)
+	c.Set(ctx, "b", []byte("2"), time.Hour)
+	if _, ok := c.Get(ctx, "a"); !ok {
+		t.Fatal("Get(a) missed")
+	}
+	c.Set(ctx, "c", []byte("3"), time.Hour)
+
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, ok := c.Get(ctx, key); ok != want {
+			t.Errorf("Get(%s) hit = %t; want %t", key, ok, want)
+		}
+	}
+
+	// Setting an existing key refreshes it rather than adding an entry.
+	c.Set(ctx, "a", []byte("4"), time.Hour)
+	c.Set(ctx, "d", []byte("5"), time.Hour)
+	if got, ok := c.Get(ctx, "a"); !ok || string(got) != "4" {
+		t.Errorf("Get(a) = %q, %t; want 4, true", got, ok)
+	}
+	if _, ok := c.Get(ctx, "c"); ok {
+		t.Error("Get(c) hit; want it evicted")
+	}
+	if n := c.Len(); n != 2 {
+		t.Errorf("Len() = %d; want 2", n)
+	}
+}
+
+func TestLRUCacheExpiresOnGet(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRUCache(10)
+
+	c.Set(ctx, "fresh", []byte("1"), time.Hour)
+	c.Set(ctx, "stale", []byte("2"), -time.Second)
+	if n := c.Len(); n != 2 {
+		t.Fatalf("Len() = %d; want 2", n)
+	}
+
+	if _, ok := c.Get(ctx, "stale"); ok {
+		t.Error("Get(stale) hit; want a miss")
+	}
+	if _, ok := c.Get(ctx, "fresh"); !ok {
+		t.Error("Get(fresh) missed")
+	}
+	if n := c.Len(); n != 1 {
+		t.Errorf("Len() = %d; want 1 after the expired entry is removed", n)
+	}
+}
@@ -0,0 +1,199 @@
+package storekit
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// CircuitBreakerSettings configure the circuit breakers of a verification
+// client. Each App Store endpoint has its own breaker, so a degraded sandbox
+// doesn't affect production verifications.
+//
+// A breaker opens after FailureThreshold consecutive failures: network errors,
+// HTTP 429 and 5xx responses, and statuses 21005, 21009 and retryable
+// 21100-21199. While open, requests fail fast with a *CircuitOpenError. After
+// OpenTimeout, up to HalfOpenRequests probe requests are let through; the
+// breaker closes if one succeeds and opens again if one fails.
+type CircuitBreakerSettings struct {
+	// FailureThreshold defaults to 5.
+	FailureThreshold int
+
+	// OpenTimeout defaults to 30 seconds.
+	OpenTimeout time.Duration
+
+	// HalfOpenRequests defaults to 1.
+	HalfOpenRequests int
+}
+
+// ErrCircuitOpen matches any *CircuitOpenError with errors.Is.
+var ErrCircuitOpen = errors.New("app store circuit breaker is open")
+
+// CircuitOpenError is returned when a request is not sent because the circuit
+// breaker of its App Store endpoint is open.
+type CircuitOpenError struct {
+	// URL is the verification endpoint whose breaker is open.
+	URL string
+}
+
+func (e *CircuitOpenError) Error() string {
+	return "app store circuit breaker is open for " + e.URL
+}
+
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// circuitBreakers holds the breaker of each endpoint, created on first use.
+type circuitBreakers struct {
+	settings CircuitBreakerSettings
+
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+func newCircuitBreakers(settings CircuitBreakerSettings) *circuitBreakers {
+	if settings.FailureThreshold < 1 {
+		settings.FailureThreshold = 5
+	}
+	if settings.OpenTimeout <= 0 {
+		settings.OpenTimeout = 30 * time.Second
+	}
+	if settings.HalfOpenRequests < 1 {
+		settings.HalfOpenRequests = 1
+	}
+	return &circuitBreakers{
+		settings: settings,
+		breakers: map[string]*circuitBreaker{},
+	}
+}
+
+func (b *circuitBreakers) get(verificationURL string) *circuitBreaker {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	breaker, ok := b.breakers[verificationURL]
+	if !ok {
+		breaker = &circuitBreaker{settings: &b.settings}
+		b.breakers[verificationURL] = breaker
+	}
+	return breaker
+}
+
+type circuitBreaker struct {
+	settings *CircuitBreakerSettings
+
+	mu       sync.Mutex
+	state    circuitState
+	failures int
+	openedAt time.Time
+	probes   int
+}
+
+// allow reports whether a request may be sent, and whether it is one of the
+// probes of a half open breaker. If it may, record must be called with the
+// request's outcome.
+func (b *circuitBreaker) allow() (ok, probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if time.Since(b.openedAt) < b.settings.OpenTimeout {
+			return false, false
+		}
+		b.state = circuitHalfOpen
+		b.probes = 0
+		fallthrough
+	case circuitHalfOpen:
+		if b.probes >= b.settings.HalfOpenRequests {
+			return false, false
+		}
+		b.probes++
+		return true, true
+	}
+	return true, false
+}
+
+// record updates the breaker with the outcome of a request it allowed. probe is
+// as returned by allow.
+func (b *circuitBreaker) record(probe bool, err error, resp *ReceiptResponse) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	outcome := circuitOutcome(err, resp)
+
+	// Requests allowed while the breaker was closed may finish once it is half
+	// open, and must not free up a probe.
+	if probe && b.state == circuitHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+
+	switch {
+	case outcome == outcomeIgnored:
+		return
+	case outcome == outcomeSuccess:
+		b.state = circuitClosed
+		b.failures = 0
+	case b.state == circuitHalfOpen:
+		b.open()
+	default:
+		b.failures++
+		if b.state == circuitClosed && b.failures >= b.settings.FailureThreshold {
+			b.open()
+		}
+	}
+}
+
+func (b *circuitBreaker) open() {
+	b.state = circuitOpen
+	b.openedAt = time.Now()
+	b.failures = 0
+}
+
+const (
+	outcomeSuccess = iota
+	outcomeFailure
+	outcomeIgnored
+)
+
+// circuitOutcome tells whether a request shows its endpoint to be healthy,
+// degraded, or neither.
+func circuitOutcome(err error, resp *ReceiptResponse) int {
+	if resp != nil {
+		switch {
+		case resp.Status == ReceiptResponseStatusReceiptServerUnavailable,
+			resp.Status == ReceiptResponseStatusBadAccess,
+			resp.Status >= 21100 && resp.Status <= 21199 && resp.IsRetryable:
+			return outcomeFailure
+		default:
+			return outcomeSuccess
+		}
+	}
+
+	switch ErrorClass(err) {
+	case ErrorClassTransport:
+		return outcomeFailure
+	case ErrorClassHTTP:
+		var httpErr *HTTPStatusError
+		errors.As(err, &httpErr)
+		if httpErr.StatusCode == http.StatusTooManyRequests || httpErr.StatusCode >= 500 {
+			return outcomeFailure
+		}
+		return outcomeSuccess
+	case ErrorClassDecode:
+		return outcomeSuccess
+	default:
+		return outcomeIgnored
+	}
+}
@@ -0,0 +1,166 @@
+package storekit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	const openTimeout = 50 * time.Millisecond
+
+	store := newAppStore(t,
+		// Two failures open the breaker.
+		ReceiptResponseStatusReceiptServerUnavailable,
+		ReceiptResponseStatusReceiptServerUnavailable,
+		// Two failed probes open it again.
+		ReceiptResponseStatusReceiptServerUnavailable,
+		ReceiptResponseStatusReceiptServerUnavailable,
+		// A successful probe closes it.
+		ReceiptResponseStatusOK,
+	)
+	store.Hold = make(chan struct{}, 10)
+
+	c, err := NewClient(
+		WithProductionURL(store.URL),
+		WithoutEnvAutoFix(),
+		WithRetryPolicy(NoRetry),
+		WithCircuitBreaker(CircuitBreakerSettings{
+			FailureThreshold: 2,
+			OpenTimeout:      openTimeout,
+			HalfOpenRequests: 2,
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &ReceiptRequest{ReceiptData: []byte("receipt")}
+
+	verify := func() error {
+		_, err := c.Verify(context.Background(), req)
+		return err
+	}
+	verifyAsync := func() <-chan error {
+		done := make(chan error, 1)
+		go func() { done <- verify() }()
+		<-store.Received
+		return done
+	}
+	expectOpen := func(when string) {
+		t.Helper()
+		if err := verify(); !errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("%s: Verify() error = %v; want ErrCircuitOpen", when, err)
+		}
+	}
+	expectStatus := func(when string, done <-chan error, status ReceiptResponseStatus) {
+		t.Helper()
+		err := <-done
+		var statusErr *StatusError
+		if status == ReceiptResponseStatusOK && err != nil ||
+			status != ReceiptResponseStatusOK && (!errors.As(err, &statusErr) || statusErr.Status != status) {
+			t.Fatalf("%s: Verify() error = %v; want status %d", when, err, status)
+		}
+	}
+
+	// Closed.
+	for i := 0; i < 2; i++ {
+		store.Hold <- struct{}{}
+		expectStatus("closed", verifyAsync(), ReceiptResponseStatusReceiptServerUnavailable)
+	}
+
+	// Open: requests fail fast without reaching the App Store.
+	expectOpen("open")
+	if got := len(store.requests()); got != 2 {
+		t.Fatalf("App Store got %d requests; want 2", got)
+	}
+
+	// Half open: up to two probes at once, failing.
+	time.Sleep(openTimeout)
+	probe1, probe2 := verifyAsync(), verifyAsync()
+	expectOpen("half open with two probes in flight")
+	store.Hold <- struct{}{}
+	store.Hold <- struct{}{}
+	expectStatus("first failed probe", probe1, ReceiptResponseStatusReceiptServerUnavailable)
+	expectStatus("second failed probe", probe2, ReceiptResponseStatusReceiptServerUnavailable)
+
+	// Open again.
+	expectOpen("reopened")
+
+	// Half open, with a successful probe.
+	time.Sleep(openTimeout)
+	store.Hold <- struct{}{}
+	expectStatus("successful probe", verifyAsync(), ReceiptResponseStatusOK)
+
+	// Closed.
+	close(store.Hold)
+	for i := 0; i < 3; i++ {
+		if err := verify(); err != nil {
+			t.Fatalf("closed again: Verify() error = %v", err)
+		}
+	}
+	if got := len(store.requests()); got != 8 {
+		t.Errorf("App Store got %d requests; want 8", got)
+	}
+}
+
+func TestCircuitBreakerIgnoresCallerErrors(t *testing.T) {
+	store := newAppStore(t, ReceiptResponseStatusOK)
+	store.Hold = make(chan struct{})
+
+	c, err := NewClient(
+		WithProductionURL(store.URL),
+		WithRetryPolicy(NoRetry),
+		WithCircuitBreaker(CircuitBreakerSettings{FailureThreshold: 1}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &ReceiptRequest{ReceiptData: []byte("receipt")}
+
+	// A caller giving up is no sign of a degraded App Store.
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.Verify(ctx, req)
+		done <- err
+	}()
+	<-store.Received
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Verify() error = %v; want context.Canceled", err)
+	}
+
+	close(store.Hold)
+	if _, err := c.Verify(context.Background(), req); err != nil {
+		t.Errorf("Verify() after cancellation error = %v", err)
+	}
+}
+
+func TestCircuitBreakerCountsOnlyProbes(t *testing.T) {
+	breaker := newCircuitBreakers(CircuitBreakerSettings{FailureThreshold: 1, HalfOpenRequests: 1}).get("url")
+	unavailable := &ReceiptResponse{Status: ReceiptResponseStatusReceiptServerUnavailable}
+
+	// A slow request is let through while the breaker is closed, and another
+	// one opens it.
+	if ok, probe := breaker.allow(); !ok || probe {
+		t.Fatalf("closed: allow() = %t, %t; want true, false", ok, probe)
+	}
+	_, probe := breaker.allow()
+	breaker.record(probe, nil, unavailable)
+
+	breaker.mu.Lock()
+	breaker.openedAt = time.Now().Add(-time.Hour)
+	breaker.mu.Unlock()
+
+	if ok, probe := breaker.allow(); !ok || !probe {
+		t.Fatalf("half open: allow() = %t, %t; want true, true", ok, probe)
+	}
+
+	// The slow request finishing doesn't make room for a second probe.
+	breaker.record(false, context.Canceled, nil)
+	if ok, _ := breaker.allow(); ok {
+		t.Error("half open with a probe in flight: allow() = true; want false")
+	}
+}
@@ -3,113 +3,375 @@ package storekit
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
-	"unicode"
+	"time"
 
 	"github.com/pkg/errors"
 )
 
+// Default App Store endpoints. Override them per client with WithSandboxURL
+// and WithProductionURL, e.g. to route through an egress proxy or to point at
+// a fake server in integration tests.
 const (
-	sandboxReceiptVerificationURL    = "https://sandbox.itunes.apple.com/verifyReceipt"
-	productionReceiptVerificationURL = "https://buy.itunes.apple.com/verifyReceipt"
+	SandboxReceiptVerificationURL    = "https://sandbox.itunes.apple.com/verifyReceipt"
+	ProductionReceiptVerificationURL = "https://buy.itunes.apple.com/verifyReceipt"
 )
 
-type client struct {
-	verificationURL    string
+// HTTPDoer is the interface satisfied by *http.Client. It lets callers plug in
+// their own transport, e.g. one with custom timeouts, proxies or a test
+// round tripper.
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Client verifies receipts with the App Store. Create one with NewClient and
+// share it: it is safe for concurrent use and never modified after creation.
+// Depend on the Verifier interface to mock it.
+type Client struct {
+	httpClient         HTTPDoer
+	environment        Environment
+	sandboxURL         string
+	productionURL      string
 	autofixEnvironment bool
+	retryPolicy        RetryPolicy
+	interceptors       []Interceptor
+	tracer             Tracer
+	metrics            Metrics
+	logger             Logger
+	maxResponseSize    int64
+	rateLimiter        *tokenBucket
+	inFlight           chan struct{}
+	circuitBreakers    *circuitBreakers
+	sharedSecrets      []string
+	sandboxPolicy      SandboxPolicy
+	strictDecoding     func(ctx context.Context, warnings []DecodeWarning)
 }
 
-// NewVerificationClient defaults to production verification URL with auto fix
-// enabled.
+// NewClient returns a client configured with opts, or the first configuration
+// error found.
+//
+// Without options, the client defaults to production verification URL with
+// auto fix enabled and DefaultRetryPolicy.
 //
-// Auto fix automatically handles the incompatible receipt environment error. It
-// subsequently gets disabled after the first attempt to avoid unexpected
-// looping.
-func NewVerificationClient() *client {
-	return &client{
-		verificationURL:    productionReceiptVerificationURL,
+// Auto fix automatically handles the incompatible receipt environment error by
+// verifying the receipt again in the other environment. It does so at most once
+// per Verify call, to avoid unexpected looping, but other statuses met before
+// it, such as the 21004 that starts a shared secret rotation, don't use it up.
+func NewClient(opts ...Option) (*Client, error) {
+	c := newClient()
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, errors.Wrap(err, "storekit")
+		}
+	}
+	return c, nil
+}
+
+// NewVerificationClient returns a client with the defaults of NewClient. Use
+// NewClient to configure anything beyond the environment and auto fix, so that
+// invalid values are reported.
+func NewVerificationClient() *Client {
+	return newClient()
+}
+
+func newClient() *Client {
+	return &Client{
+		httpClient:         NewDefaultHTTPClient(),
+		environment:        EnvironmentProduction,
+		sandboxURL:         SandboxReceiptVerificationURL,
+		productionURL:      ProductionReceiptVerificationURL,
 		autofixEnvironment: true,
+		retryPolicy:        DefaultRetryPolicy,
+		tracer:             noopTracer{},
+		metrics:            noopMetrics{},
+		logger:             noopLogger{},
+		maxResponseSize:    DefaultMaxResponseSize,
+	}
+}
+
+// NewDefaultHTTPClient returns the *http.Client used by a verification client
+// unless one is set with WithHTTPClient. It has its own connection pool (so it
+// isn't shared with http.DefaultClient), keeps connections alive between
+// verifications and requires TLS 1.2 or later.
+func NewDefaultHTTPClient() *http.Client {
+	return &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   10 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			MaxIdleConnsPerHost:   10,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+			TLSClientConfig: &tls.Config{
+				MinVersion: tls.VersionTLS12,
+			},
+		},
 	}
 }
 
-// OnProductionEnv sets the client to use sandbox URL for verification.
-func (c *client) OnSandboxEnv() *client {
-	c.verificationURL = sandboxReceiptVerificationURL
-	return c
+// Environment is the App Store environment a receipt was verified against.
+type Environment string
+
+const (
+	EnvironmentSandbox    Environment = "Sandbox"
+	EnvironmentProduction Environment = "Production"
+)
+
+// OnSandboxEnv returns a copy of the client that uses sandbox URL for
+// verification.
+func (c *Client) OnSandboxEnv() *Client {
+	cc := *c
+	cc.environment = EnvironmentSandbox
+	return &cc
 }
 
-// OnProductionEnv sets the client to use production URL for verification.
-func (c *client) OnProductionEnv() *client {
-	c.verificationURL = productionReceiptVerificationURL
-	return c
+// OnProductionEnv returns a copy of the client that uses production URL for
+// verification.
+func (c *Client) OnProductionEnv() *Client {
+	cc := *c
+	cc.environment = EnvironmentProduction
+	return &cc
 }
 
-// WithoutEnvAutoFix disables automatic handling of incompatible receipt
-// environment error.
-func (c *client) WithoutEnvAutoFix() *client {
-	c.autofixEnvironment = false
-	return c
+// WithoutEnvAutoFix returns a copy of the client with automatic handling of
+// incompatible receipt environment error disabled.
+func (c *Client) WithoutEnvAutoFix() *Client {
+	cc := *c
+	cc.autofixEnvironment = false
+	return &cc
 }
 
-func (c *client) Verify(ctx context.Context, req *ReceiptRequest) ([]byte, *ReceiptResponse, error) {
-post:
-	body, err := c.post(ctx, req)
+// Verify sends the receipt to the App Store and returns the result of the
+// verification, which is never nil.
+//
+// If the App Store answers with a status other than ReceiptResponseStatusOK, a
+// *StatusError is returned along with the result, which still holds the body
+// and response. Other failures are reported as *TransportError,
+// *HTTPStatusError or *DecodeError. Use IsRetryable, IsPermanent and
+// IsMisconfiguration to classify them.
+//
+// The client is never modified by Verify, so it is safe to share one between
+// goroutines. Environment auto fix is resolved for each call separately.
+func (c *Client) Verify(ctx context.Context, req *ReceiptRequest) (result *VerifyResult, err error) {
+	start := time.Now()
+	ctx, span := c.tracer.Start(ctx, "storekit.Verify")
+	defer func() {
+		endVerifySpan(span, result, err)
+		c.logVerify(ctx, result, err)
+		c.metrics.ObserveVerify(result, time.Since(start), err)
+	}()
+
+	result = &VerifyResult{SharedSecretIndex: -1}
+	if req == nil {
+		return result, ErrNilRequest
+	}
+
+	env := c.environment
+	autofixEnvironment := c.autofixEnvironment
+
+	// Shared secrets of the client are only used for requests without a password
+	// of their own.
+	var secrets []string
+	if req.Password == "" {
+		secrets = c.sharedSecrets
+	}
+	secretIndex := 0
+
+	for {
+		secretReq := req
+		if len(secrets) > 0 {
+			r := *req
+			r.Password = secrets[secretIndex]
+			secretReq = &r
+			result.SharedSecretIndex = secretIndex
+		}
+
+		err = c.verifyWithRetry(ctx, env, secretReq, result)
+
+		if result.Response != nil && autofixEnvironment {
+			// Auto fix but only once. Other statuses, such as the 21004 that starts a
+			// shared secret rotation, leave it enabled for the next response.
+			switch result.Response.Status {
+			case ReceiptResponseStatusSandboxReceiptSentToProduction:
+				// On a 21007 status, retry the request in the sandbox environment (only if the
+				// current environment is production – to avoid unexpected loop).
+				//
+				// These are receipts from Apple review team.
+				if env == EnvironmentProduction {
+					env = EnvironmentSandbox
+					autofixEnvironment = false
+					result.EnvironmentAutoFixed = true
+					continue
+				}
+			case ReceiptResponseStatusProductionReceiptSentToSandbox:
+				// On a 21008 status, retry the request in the production environment (only if
+				// the current environment is sandbox – to avoid unexpected loop).
+				if env == EnvironmentSandbox {
+					env = EnvironmentProduction
+					autofixEnvironment = false
+					result.EnvironmentAutoFixed = true
+					continue
+				}
+			}
+		}
+
+		// On a 21004 status, the secret may have been rotated in App Store Connect.
+		// Try the next one.
+		if result.Response != nil &&
+			result.Response.Status == ReceiptResponseStatusSharedSecretDoesNotMatch &&
+			secretIndex+1 < len(secrets) {
+			secretIndex++
+			continue
+		}
+
+		result.URL = c.urlOf(env)
+		result.Environment = env
+		result.Sandbox = env == EnvironmentSandbox
+
+		if err == nil && !sandboxPolicyDeferred(ctx) {
+			err = c.checkSandboxPolicy(ctx, secretReq, result)
+		}
+
+		return result, err
+	}
+}
+
+func (c *Client) checkSandboxPolicy(ctx context.Context, req *ReceiptRequest, result *VerifyResult) error {
+	if result.Sandbox && result.Response != nil && c.environment == EnvironmentProduction &&
+		c.sandboxPolicy != nil && !c.sandboxPolicy.AllowSandbox(ctx, req, result.Response) {
+		return ErrSandboxReceiptRejected
+	}
+	return nil
+}
+
+// verifyWithRetry verifies the receipt against a single endpoint, retrying
+// transient failures as allowed by the retry policy. Once attempts run out, the
+// last error is returned as is.
+func (c *Client) verifyWithRetry(ctx context.Context, env Environment, req *ReceiptRequest, result *VerifyResult) error {
+	for attempt := 1; ; attempt++ {
+		err := c.verifyAt(ctx, env, req, attempt > 1, result)
+		if !IsRetryable(err) || errors.Is(err, ErrCircuitOpen) {
+			// An open circuit is meant to fail fast.
+			return err
+		}
+		if attempt >= c.retryPolicy.MaxAttempts || !c.retryPolicy.wait(ctx, attempt) {
+			return err
+		}
+	}
+}
+
+// verifyAt makes a single request to the App Store, records it in the result's
+// attempts and replaces the result's body and response with its own.
+func (c *Client) verifyAt(ctx context.Context, env Environment, req *ReceiptRequest, retry bool, result *VerifyResult) error {
+	verificationURL := c.urlOf(env)
+	attempt := Attempt{
+		Number:      len(result.Attempts) + 1,
+		URL:         verificationURL,
+		Environment: env,
+		Retry:       retry,
+	}
+
+	ctx, span := c.tracer.Start(ctx, "storekit.Attempt")
+
+	// Interceptors may modify the request, but never the caller's copy.
+	attemptReq := *req
+	ctx, n, err := c.beforeSend(ctx, &attempt, &attemptReq)
+
+	var body []byte
+	var resp *ReceiptResponse
+	start := time.Now()
+	if err == nil {
+		body, resp, err = c.guardedRoundTrip(ctx, verificationURL, &attemptReq, &attempt, &start)
+	}
+	attempt.Latency = time.Since(start)
+	attempt.Err = err
+	if resp != nil {
+		attempt.Status = resp.Status
+	}
+
+	c.afterReceive(ctx, n, &attempt, resp, err)
+	endAttemptSpan(span, &attempt, resp)
+	c.metrics.ObserveAttempt(&attempt)
+	c.logAttempt(ctx, &attempt, &attemptReq)
+
+	result.Attempts = append(result.Attempts, attempt)
+	result.Body = body
+	result.Response = resp
+
+	return err
+}
+
+// guardedRoundTrip makes the request once the circuit breaker and the limits
+// allow it. start is reset to the time the request is actually sent.
+func (c *Client) guardedRoundTrip(ctx context.Context, verificationURL string, req *ReceiptRequest, attempt *Attempt, start *time.Time) ([]byte, *ReceiptResponse, error) {
+	var breaker *circuitBreaker
+	var probe bool
+	if c.circuitBreakers != nil {
+		breaker = c.circuitBreakers.get(verificationURL)
+		var ok bool
+		if ok, probe = breaker.allow(); !ok {
+			return nil, nil, &CircuitOpenError{URL: verificationURL}
+		}
+	}
+
+	release, err := c.acquire(ctx)
 	if err != nil {
+		if breaker != nil {
+			breaker.record(probe, err, nil)
+		}
 		return nil, nil, err
 	}
+	defer release()
 
-	resp := &ReceiptResponse{}
-	err = json.Unmarshal(
-		bytes.Map(func(r rune) rune {
-			if unicode.IsControl(r) {
-				return -1
-			}
-			return r
-		}, body),
-		resp,
-	)
+	// Time spent waiting for the limits isn't App Store latency.
+	*start = time.Now()
+	body, resp, err := c.roundTrip(ctx, verificationURL, req, attempt)
+
+	if breaker != nil {
+		breaker.record(probe, err, resp)
+	}
+	return body, resp, err
+}
+
+func (c *Client) roundTrip(ctx context.Context, verificationURL string, req *ReceiptRequest, attempt *Attempt) ([]byte, *ReceiptResponse, error) {
+	r, err := c.post(ctx, verificationURL, req, attempt)
 	if err != nil {
-		return nil, nil, errors.Wrap(err, "could not unmarshal app store response")
-	}
-
-	if c.autofixEnvironment {
-		// Auto fix but only once.
-		c.autofixEnvironment = false
-
-		switch resp.Status {
-		case ReceiptResponseStatusSandboxReceiptSentToProduction:
-			// On a 21007 status, retry the request in the sandbox environment (only if the
-			// current environment is production – to avoid unexpected loop).
-			//
-			// These are receipts from Apple review team.
-			if c.isProduction() {
-				c.verificationURL = sandboxReceiptVerificationURL
-				goto post
-			}
-		case ReceiptResponseStatusProductionReceiptSentToSandbox:
-			// On a 21008 status, retry the request in the production environment (only if
-			// the current environment is sandbox – to avoid unexpected loop).
-			if c.isSandbox() {
-				c.verificationURL = productionReceiptVerificationURL
-				goto post
-			}
-		default:
-			// TODO: Retry at least once when an App Store internal error occurs here:
-			// 	if resp.Status >= 21100 && resp.Status <= 21199 {
-			// 		if resp.IsRetryable {
-			// 			goto post
-			// 		}
-			// 	}
-			break
+		return nil, nil, err
+	}
+	defer r.Body.Close()
+
+	// Parse response:
+
+	body, resp, err := decodeResponse(r.Body, r.ContentLength, c.maxResponseSize)
+	_, isDecodeErr := err.(*DecodeError)
+
+	// Schema drift is most interesting when it breaks regular decoding.
+	if c.strictDecoding != nil && (err == nil || isDecodeErr && !errors.Is(err, ErrResponseTooLarge)) {
+		c.reportSchemaDrift(ctx, body)
+	}
+
+	if err != nil {
+		if !isDecodeErr {
+			err = &TransportError{URL: verificationURL, Err: err}
 		}
+		return body, nil, err
 	}
 
-	return body, resp, nil
+	return body, resp, statusError(resp)
 }
 
-func (c *client) post(ctx context.Context, receiptRequest *ReceiptRequest) ([]byte, error) {
+func (c *Client) post(ctx context.Context, verificationURL string, receiptRequest *ReceiptRequest, attempt *Attempt) (*http.Response, error) {
 	// Prepare request:
 
 	reqJSON, err := json.Marshal(receiptRequest)
@@ -121,36 +383,32 @@ func (c *client) post(ctx context.Context, receiptRequest *ReceiptRequest) ([]by
 
 	buf := bytes.NewReader(reqJSON)
 
-	req, err := http.NewRequest("POST", c.verificationURL, buf)
+	req, err := http.NewRequest("POST", verificationURL, buf)
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrap(err, "could not create app store request")
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req = req.WithContext(ctx)
-	r, err := http.DefaultClient.Do(req)
+	r, err := c.httpClient.Do(req)
 	if err != nil {
-		// TODO: Handle this error (and probably retry at least once):
+		// Connection errors such as the following are usually gone on the next try:
 		//       Post https://sandbox.itunes.apple.com/verifyReceipt: read tcp 10.1.11.101:36372->17.154.66.159:443: read: connection reset by peer
-		return nil, errors.Wrap(err, "could not connect to app store server")
+		return nil, &TransportError{URL: verificationURL, Err: err}
 	}
+	attempt.HTTPStatusCode = r.StatusCode
 	if r.StatusCode != http.StatusOK {
-		return nil, errors.New("app store http error (" + r.Status + ")")
-	}
-
-	// Parse response:
-
-	body, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		return nil, errors.Wrap(err, "could not read app store response")
+		// Drain a little so that the connection can be reused.
+		_, _ = io.CopyN(ioutil.Discard, r.Body, 4<<10)
+		r.Body.Close()
+		return nil, &HTTPStatusError{URL: verificationURL, StatusCode: r.StatusCode, Status: r.Status}
 	}
 
-	return body, nil
+	return r, nil
 }
 
-func (c *client) isSandbox() bool {
-	return c.verificationURL == sandboxReceiptVerificationURL
-}
-
-func (c *client) isProduction() bool {
-	return c.verificationURL == productionReceiptVerificationURL
+func (c *Client) urlOf(env Environment) string {
+	if env == EnvironmentSandbox {
+		return c.sandboxURL
+	}
+	return c.productionURL
 }
@@ -0,0 +1,178 @@
+package storekit
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// appStore is a fake App Store endpoint that answers with the given statuses in
+// turn, repeating the last one, and records the passwords it was sent. Set its
+// exported fields before the first request.
+type appStore struct {
+	*httptest.Server
+
+	// Environment and BundleID are set on the responses.
+	Environment Environment
+	BundleID    string
+
+	// Hold, if set, keeps each request waiting until it receives from it; close
+	// it to release them all. Received gets a value as each request arrives, and
+	// Canceled as each held request is canceled by the client.
+	Hold     chan struct{}
+	Received chan struct{}
+	Canceled chan struct{}
+
+	mu        sync.Mutex
+	statuses  []ReceiptResponseStatus
+	passwords []string
+}
+
+func newAppStore(t *testing.T, statuses ...ReceiptResponseStatus) *appStore {
+	s := &appStore{
+		statuses: statuses,
+		Received: make(chan struct{}, 100),
+		Canceled: make(chan struct{}, 100),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Reading the body to the end lets the server notice the client going away.
+		var req ReceiptRequest
+		body, err := ioutil.ReadAll(r.Body)
+		if err == nil {
+			err = json.Unmarshal(body, &req)
+		}
+		if err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+
+		s.mu.Lock()
+		s.passwords = append(s.passwords, req.Password)
+		s.mu.Unlock()
+
+		s.Received <- struct{}{}
+		if s.Hold != nil {
+			select {
+			case <-s.Hold:
+			case <-r.Context().Done():
+				s.Canceled <- struct{}{}
+				return
+			}
+		}
+
+		s.mu.Lock()
+		status := s.statuses[0]
+		if len(s.statuses) > 1 {
+			s.statuses = s.statuses[1:]
+		}
+		s.mu.Unlock()
+
+		_ = json.NewEncoder(w).Encode(ReceiptResponse{
+			Status:      status,
+			Environment: string(s.Environment),
+			Receipt:     Receipt{BundleId: s.BundleID},
+		})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *appStore) requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.passwords...)
+}
+
+func TestVerifySecretRotationFallsBackToSandbox(t *testing.T) {
+	production := newAppStore(t,
+		ReceiptResponseStatusSharedSecretDoesNotMatch,
+		ReceiptResponseStatusSandboxReceiptSentToProduction,
+	)
+	sandbox := newAppStore(t, ReceiptResponseStatusOK)
+
+	c, err := NewClient(
+		WithProductionURL(production.URL),
+		WithSandboxURL(sandbox.URL),
+		WithSharedSecrets("stale", "good"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := c.Verify(context.Background(), &ReceiptRequest{ReceiptData: []byte("receipt")})
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if result.Environment != EnvironmentSandbox || !result.EnvironmentAutoFixed {
+		t.Errorf("Verify() environment = %s, auto fixed = %t; want Sandbox, true", result.Environment, result.EnvironmentAutoFixed)
+	}
+	if result.SharedSecretIndex != 1 {
+		t.Errorf("Verify() shared secret index = %d; want 1", result.SharedSecretIndex)
+	}
+	if got := len(result.Attempts); got != 3 {
+		t.Errorf("Verify() attempts = %d; want 3", got)
+	}
+	if got := sandbox.requests(); len(got) != 1 || got[0] != "good" {
+		t.Errorf("sandbox passwords = %q; want [good]", got)
+	}
+}
+
+func TestVerifyAutoFixesOnce(t *testing.T) {
+	production := newAppStore(t, ReceiptResponseStatusSandboxReceiptSentToProduction)
+	sandbox := newAppStore(t, ReceiptResponseStatusProductionReceiptSentToSandbox)
+
+	c, err := NewClient(WithProductionURL(production.URL), WithSandboxURL(sandbox.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := c.Verify(context.Background(), &ReceiptRequest{ReceiptData: []byte("receipt")})
+	if !IsMisconfiguration(err) {
+		t.Fatalf("Verify() error = %v; want a misconfiguration", err)
+	}
+	if got := len(result.Attempts); got != 2 {
+		t.Errorf("Verify() attempts = %d; want 2", got)
+	}
+}
+
+func TestNewClientRejectsInvalidOptions(t *testing.T) {
+	tests := map[string]Option{
+		"sandbox url without scheme": WithSandboxURL("localhost:9999"),
+		"production url":             WithProductionURL("ftp://example.com"),
+		"negative max attempts":      WithRetryPolicy(RetryPolicy{MaxAttempts: -1}),
+		"negative rate":              WithRateLimit(-1, 1),
+	}
+	for name, opt := range tests {
+		if c, err := NewClient(opt); err == nil {
+			t.Errorf("%s: NewClient() = %+v; want an error", name, c)
+		}
+	}
+}
+
+func TestVerifiersRejectNilRequest(t *testing.T) {
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRouter(c, App{BundleID: "com.example.a", Verifier: c})
+
+	verifiers := map[string]Verifier{
+		"client":       c,
+		"deduplicator": NewDeduplicator(c),
+		"cache":        NewCachingVerifier(c, NewLRUCache(10), time.Hour),
+		"router":       r,
+		"router app": VerifierFunc(func(ctx context.Context, req *ReceiptRequest) (*VerifyResult, error) {
+			return r.VerifyApp(ctx, "com.example.a", req)
+		}),
+	}
+	for name, v := range verifiers {
+		result, err := v.Verify(context.Background(), nil)
+		if err != ErrNilRequest || result == nil {
+			t.Errorf("%s: Verify(nil) = %v, %v; want a result and ErrNilRequest", name, result, err)
+		}
+	}
+}
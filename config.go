@@ -0,0 +1,138 @@
+package storekit
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Config is a serializable client configuration, e.g. loaded from a JSON
+// config file. Zero values keep the defaults of NewClient.
+type Config struct {
+	// Environment is "Sandbox" or "Production".
+	Environment Environment `json:"environment,omitempty"`
+
+	DisableEnvAutoFix bool     `json:"disable_env_auto_fix,omitempty"`
+	SandboxURL        string   `json:"sandbox_url,omitempty"`
+	ProductionURL     string   `json:"production_url,omitempty"`
+	SharedSecrets     []string `json:"shared_secrets,omitempty"`
+	MaxResponseSize   int64    `json:"max_response_size,omitempty"`
+	RatePerSecond     float64  `json:"rate_per_second,omitempty"`
+	Burst             int      `json:"burst,omitempty"`
+	MaxInFlight       int      `json:"max_in_flight,omitempty"`
+
+	Retry          *RetryConfig          `json:"retry,omitempty"`
+	CircuitBreaker *CircuitBreakerConfig `json:"circuit_breaker,omitempty"`
+}
+
+// RetryConfig is the serializable form of a RetryPolicy. Zero fields keep the
+// values of DefaultRetryPolicy; set MaxAttempts to 1 to disable retries.
+type RetryConfig struct {
+	MaxAttempts    int      `json:"max_attempts,omitempty"`
+	InitialBackoff Duration `json:"initial_backoff,omitempty"`
+	MaxBackoff     Duration `json:"max_backoff,omitempty"`
+	Multiplier     float64  `json:"multiplier,omitempty"`
+	Jitter         float64  `json:"jitter,omitempty"`
+}
+
+// CircuitBreakerConfig is the serializable form of CircuitBreakerSettings.
+type CircuitBreakerConfig struct {
+	FailureThreshold int      `json:"failure_threshold,omitempty"`
+	OpenTimeout      Duration `json:"open_timeout,omitempty"`
+	HalfOpenRequests int      `json:"half_open_requests,omitempty"`
+}
+
+// Duration is a time.Duration that reads from and writes to JSON as a string
+// such as "1.5s". Plain numbers are read as nanoseconds.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var ns int64
+		if err := json.Unmarshal(data, &ns); err != nil {
+			return errors.Errorf("invalid duration %s", data)
+		}
+		*d = Duration(ns)
+		return nil
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return errors.Wrap(err, "invalid duration")
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Options returns the options applying the configuration.
+func (cfg Config) Options() []Option {
+	var opts []Option
+	if cfg.Environment != "" {
+		opts = append(opts, WithEnvironment(cfg.Environment))
+	}
+	if cfg.DisableEnvAutoFix {
+		opts = append(opts, WithoutEnvAutoFix())
+	}
+	if cfg.SandboxURL != "" {
+		opts = append(opts, WithSandboxURL(cfg.SandboxURL))
+	}
+	if cfg.ProductionURL != "" {
+		opts = append(opts, WithProductionURL(cfg.ProductionURL))
+	}
+	if len(cfg.SharedSecrets) > 0 {
+		opts = append(opts, WithSharedSecrets(cfg.SharedSecrets...))
+	}
+	if cfg.MaxResponseSize != 0 {
+		opts = append(opts, WithMaxResponseSize(cfg.MaxResponseSize))
+	}
+	if cfg.RatePerSecond != 0 || cfg.Burst != 0 {
+		opts = append(opts, WithRateLimit(cfg.RatePerSecond, cfg.Burst))
+	}
+	if cfg.MaxInFlight != 0 {
+		opts = append(opts, WithMaxInFlight(cfg.MaxInFlight))
+	}
+	if cfg.Retry != nil {
+		opts = append(opts, WithRetryPolicy(cfg.Retry.policy()))
+	}
+	if cfg.CircuitBreaker != nil {
+		opts = append(opts, WithCircuitBreaker(CircuitBreakerSettings{
+			FailureThreshold: cfg.CircuitBreaker.FailureThreshold,
+			OpenTimeout:      time.Duration(cfg.CircuitBreaker.OpenTimeout),
+			HalfOpenRequests: cfg.CircuitBreaker.HalfOpenRequests,
+		}))
+	}
+	return opts
+}
+
+// policy returns DefaultRetryPolicy with the non-zero fields of rc applied.
+func (rc RetryConfig) policy() RetryPolicy {
+	policy := DefaultRetryPolicy
+	if rc.MaxAttempts != 0 {
+		policy.MaxAttempts = rc.MaxAttempts
+	}
+	if rc.InitialBackoff != 0 {
+		policy.InitialBackoff = time.Duration(rc.InitialBackoff)
+	}
+	if rc.MaxBackoff != 0 {
+		policy.MaxBackoff = time.Duration(rc.MaxBackoff)
+	}
+	if rc.Multiplier != 0 {
+		policy.Multiplier = rc.Multiplier
+	}
+	if rc.Jitter != 0 {
+		policy.Jitter = rc.Jitter
+	}
+	return policy
+}
+
+// NewClientFromConfig returns a client configured with cfg, followed by opts
+// for what can't be serialized, such as loggers and metrics.
+func NewClientFromConfig(cfg Config, opts ...Option) (*Client, error) {
+	return NewClient(append(cfg.Options(), opts...)...)
+}
@@ -0,0 +1,108 @@
+package storekit
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewClientFromConfig(t *testing.T) {
+	var cfg Config
+	err := json.Unmarshal([]byte(`{
+		"environment": "Sandbox",
+		"disable_env_auto_fix": true,
+		"sandbox_url": "http://sandbox.example.com/verifyReceipt",
+		"shared_secrets": ["a", "b"],
+		"max_response_size": 1024,
+		"rate_per_second": 5,
+		"burst": 10,
+		"max_in_flight": 3,
+		"retry": {"max_backoff": "10s", "jitter": 0.5},
+		"circuit_breaker": {"failure_threshold": 2, "open_timeout": "1m"}
+	}`), &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewClientFromConfig(cfg, WithMaxInFlight(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.environment != EnvironmentSandbox || c.autofixEnvironment {
+		t.Errorf("environment = %s, auto fix = %t; want Sandbox, false", c.environment, c.autofixEnvironment)
+	}
+	if c.sandboxURL != "http://sandbox.example.com/verifyReceipt" || c.productionURL != ProductionReceiptVerificationURL {
+		t.Errorf("URLs = %s, %s", c.sandboxURL, c.productionURL)
+	}
+	if !reflect.DeepEqual(c.sharedSecrets, []string{"a", "b"}) || c.maxResponseSize != 1024 {
+		t.Errorf("shared secrets = %q, max response size = %d", c.sharedSecrets, c.maxResponseSize)
+	}
+	if c.rateLimiter == nil || c.rateLimiter.rate != 5 || c.rateLimiter.burst != 10 {
+		t.Errorf("rate limiter = %+v; want 5/s, burst 10", c.rateLimiter)
+	}
+	if cap(c.inFlight) != 4 {
+		t.Errorf("max in flight = %d; want the option's 4 to override the config's 3", cap(c.inFlight))
+	}
+
+	// Retry fields left out keep their defaults.
+	want := DefaultRetryPolicy
+	want.MaxBackoff = 10 * time.Second
+	want.Jitter = 0.5
+	if c.retryPolicy != want {
+		t.Errorf("retry policy = %+v; want %+v", c.retryPolicy, want)
+	}
+
+	if c.circuitBreakers == nil {
+		t.Fatal("no circuit breaker")
+	}
+	if s := c.circuitBreakers.settings; s.FailureThreshold != 2 || s.OpenTimeout != time.Minute || s.HalfOpenRequests != 1 {
+		t.Errorf("circuit breaker settings = %+v", s)
+	}
+}
+
+func TestNewClientFromConfigRejectsInvalidConfig(t *testing.T) {
+	tests := map[string]Config{
+		"environment":   {Environment: "Staging"},
+		"sandbox url":   {SandboxURL: "::"},
+		"rate":          {RatePerSecond: -1},
+		"retry":         {Retry: &RetryConfig{Jitter: 2}},
+		"max in flight": {MaxInFlight: -1},
+	}
+	for name, cfg := range tests {
+		if _, err := NewClientFromConfig(cfg); err == nil {
+			t.Errorf("%s: NewClientFromConfig() succeeded; want an error", name)
+		}
+	}
+}
+
+func TestDurationJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Duration
+		out  string
+	}{
+		{`"1.5s"`, Duration(1500 * time.Millisecond), `"1.5s"`},
+		{`"2m"`, Duration(2 * time.Minute), `"2m0s"`},
+		{`"0s"`, 0, `"0s"`},
+		{`250000000`, Duration(250 * time.Millisecond), `"250ms"`},
+	}
+	for _, tt := range tests {
+		var d Duration
+		if err := json.Unmarshal([]byte(tt.in), &d); err != nil || d != tt.want {
+			t.Errorf("Unmarshal(%s) = %v, %v; want %v", tt.in, time.Duration(d), err, time.Duration(tt.want))
+			continue
+		}
+		if out, err := json.Marshal(d); err != nil || string(out) != tt.out {
+			t.Errorf("Marshal(%v) = %s, %v; want %s", time.Duration(d), out, err, tt.out)
+		}
+	}
+
+	for _, in := range []string{`"soon"`, `"5"`, `1.5`, `true`} {
+		var d Duration
+		if err := json.Unmarshal([]byte(in), &d); err == nil {
+			t.Errorf("Unmarshal(%s) = %v; want an error", in, time.Duration(d))
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package storekit
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// appleDateLayout is the layout of the date-time fields "similar to ISO 8601"
+// in App Store responses, without the trailing time zone name, e.g.
+// "2020-09-13 12:26:40 Etc/GMT".
+const appleDateLayout = "2006-01-02 15:04:05"
+
+// ParseDate parses a date-time field of an App Store response, either the one
+// "similar to ISO 8601" (e.g. "2020-09-13 12:26:40 Etc/GMT") or the one in the
+// Pacific Time zone (e.g. "2020-09-13 05:26:40 America/Los_Angeles").
+func ParseDate(s string) (time.Time, error) {
+	i := strings.LastIndexByte(s, ' ')
+	if i < 0 {
+		return time.Time{}, errors.Errorf("invalid app store date %q: no time zone", s)
+	}
+	wall, zone := s[:i], s[i+1:]
+
+	switch zone {
+	case "Etc/GMT", "GMT", "Etc/UTC", "UTC":
+		t, err := time.ParseInLocation(appleDateLayout, wall, time.UTC)
+		return t, errors.Wrapf(err, "invalid app store date %q", s)
+	case "America/Los_Angeles":
+		return parsePacificDate(s, wall)
+	}
+
+	loc, err := loadLocation(zone)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "invalid app store date %q", s)
+	}
+	t, err := time.ParseInLocation(appleDateLayout, wall, loc)
+	return t, errors.Wrapf(err, "invalid app store date %q", s)
+}
+
+var locations sync.Map // Zone name -> *time.Location or error.
+
+// loadLocation is time.LoadLocation, remembering its outcome for each zone.
+func loadLocation(zone string) (*time.Location, error) {
+	if v, ok := locations.Load(zone); ok {
+		if err, ok := v.(error); ok {
+			return nil, err
+		}
+		return v.(*time.Location), nil
+	}
+
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		locations.Store(zone, err)
+		return nil, err
+	}
+	locations.Store(zone, loc)
+	return loc, nil
+}
+
+// parsePacificDate parses a wall time in the Pacific Time zone. Without time
+// zone data on the system, the US daylight saving time rules in effect since
+// 2007 are applied.
+func parsePacificDate(s, wall string) (time.Time, error) {
+	loc, err := loadLocation("America/Los_Angeles")
+	if err != nil {
+		loc = time.UTC // Parsed as is, then shifted by pacificOffset.
+	}
+
+	t, err := time.ParseInLocation(appleDateLayout, wall, loc)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "invalid app store date %q", s)
+	}
+	if loc == time.UTC {
+		t = t.Add(-pacificOffset(t))
+	}
+	return t, nil
+}
+
+// pacificOffset returns the offset from UTC of the Pacific Time zone at the
+// wall time wall, given in UTC.
+func pacificOffset(wall time.Time) time.Duration {
+	// Daylight saving time runs from 2:00 on the second Sunday of March to 2:00
+	// on the first Sunday of November, in wall time.
+	dstStart := nthSunday(wall.Year(), time.March, 2).Add(2 * time.Hour)
+	dstEnd := nthSunday(wall.Year(), time.November, 1).Add(2 * time.Hour)
+
+	if !wall.Before(dstStart) && wall.Before(dstEnd) {
+		return -7 * time.Hour
+	}
+	return -8 * time.Hour
+}
+
+func nthSunday(year int, month time.Month, n int) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	days := (7 - int(first.Weekday())) % 7
+	return first.AddDate(0, 0, days+7*(n-1))
+}
+
+// DateMismatch reports a date of an App Store response whose three variants
+// (e.g. expires_date, expires_date_ms and expires_date_pst) disagree, which
+// happens in corrupted or tampered payloads.
+type DateMismatch struct {
+	// Path locates the date in the response, e.g.
+	// "latest_receipt_info[0].expires_date".
+	Path string
+
+	Date    string
+	DateMs  Timestamp
+	DatePst string
+
+	// Err describes the disagreement, or why a variant could not be parsed.
+	Err error
+}
+
+func (m DateMismatch) Error() string {
+	return m.Path + ": " + m.Err.Error()
+}
+
+// CheckDates cross-checks the date variants of the response, including those of
+// its receipt, in-app purchases, latest receipt info and pending renewal info.
+// Variants that are absent are not checked.
+func (r *ReceiptResponse) CheckDates() []DateMismatch {
+	var mismatches []DateMismatch
+	checkDates(reflect.ValueOf(r).Elem(), "", &mismatches)
+	return mismatches
+}
+
+// CheckDates cross-checks the date variants of the notification, including
+// those of its unified receipt. Variants that are absent are not checked.
+func (n *Notification) CheckDates() []DateMismatch {
+	var mismatches []DateMismatch
+	checkDates(reflect.ValueOf(n).Elem(), "", &mismatches)
+	return mismatches
+}
+
+var timestampType = reflect.TypeOf(Timestamp(0))
+
+// checkDates walks the struct v looking for Timestamp fields named FooMs with
+// string siblings Foo and FooPst.
+func checkDates(v reflect.Value, path string, mismatches *[]DateMismatch) {
+	prefix := path
+	if prefix != "" {
+		prefix += "."
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := jsonName(field)
+		if name == "-" {
+			continue
+		}
+
+		fv := v.Field(i)
+		switch {
+		case field.Type == timestampType && strings.HasSuffix(field.Name, "Ms"):
+			base := strings.TrimSuffix(field.Name, "Ms")
+			date, _ := t.FieldByName(base)
+			datePst, _ := t.FieldByName(base + "Pst")
+			if date.Type == nil || datePst.Type == nil {
+				continue
+			}
+			m := DateMismatch{
+				Path:    prefix + jsonName(date),
+				Date:    v.FieldByIndex(date.Index).String(),
+				DateMs:  Timestamp(fv.Int()),
+				DatePst: v.FieldByIndex(datePst.Index).String(),
+			}
+			if m.Err = compareDates(m.Date, m.DateMs, m.DatePst); m.Err != nil {
+				*mismatches = append(*mismatches, m)
+			}
+
+		case field.Type.Kind() == reflect.Struct:
+			checkDates(fv, prefix+name, mismatches)
+
+		case field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.Struct:
+			for j := 0; j < fv.Len(); j++ {
+				checkDates(fv.Index(j), prefix+name+"["+strconv.Itoa(j)+"]", mismatches)
+			}
+		}
+	}
+}
+
+// compareDates returns an error if any two of the present variants of a date
+// are more than a second apart (the date-time variants have second precision).
+func compareDates(date string, ms Timestamp, datePst string) error {
+	var times []time.Time
+	var names []string
+
+	if !ms.IsZero() {
+		times = append(times, ms.Time().Truncate(time.Second))
+		names = append(names, "ms")
+	}
+	for _, variant := range []struct{ name, value string }{{"date", date}, {"pst", datePst}} {
+		if variant.value == "" {
+			continue
+		}
+		t, err := ParseDate(variant.value)
+		if err != nil {
+			return err
+		}
+		times = append(times, t)
+		names = append(names, variant.name)
+	}
+
+	for i := 1; i < len(times); i++ {
+		if d := times[i].Sub(times[0]); d >= time.Second || d <= -time.Second {
+			return errors.Errorf("%s variant is %s off the %s variant", names[i], d, names[0])
+		}
+	}
+	return nil
+}
+
+func jsonName(field reflect.StructField) string {
+	tag, ok := field.Tag.Lookup("json")
+	if !ok {
+		return field.Name
+	}
+	if name := strings.Split(tag, ",")[0]; name != "" {
+		return name
+	}
+	return field.Name
+}
@@ -0,0 +1,169 @@
+package storekit
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// pacificDates are wall times in the Pacific Time zone around the 2020
+// daylight saving time transitions, with the instants they stand for.
+var pacificDates = []struct {
+	wall string
+	want time.Time
+}{
+	{"2020-01-15 12:00:00", time.Date(2020, 1, 15, 20, 0, 0, 0, time.UTC)},
+	{"2020-03-08 01:59:59", time.Date(2020, 3, 8, 9, 59, 59, 0, time.UTC)},
+	{"2020-03-08 03:00:00", time.Date(2020, 3, 8, 10, 0, 0, 0, time.UTC)},
+	{"2020-07-04 09:30:00", time.Date(2020, 7, 4, 16, 30, 0, 0, time.UTC)},
+	{"2020-11-01 00:59:59", time.Date(2020, 11, 1, 7, 59, 59, 0, time.UTC)},
+	{"2020-11-01 02:00:00", time.Date(2020, 11, 1, 10, 0, 0, 0, time.UTC)},
+	{"2020-12-31 23:59:59", time.Date(2021, 1, 1, 7, 59, 59, 0, time.UTC)},
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"2020-09-13 12:26:40 Etc/GMT", time.Date(2020, 9, 13, 12, 26, 40, 0, time.UTC)},
+		{"2020-09-13 12:26:40 UTC", time.Date(2020, 9, 13, 12, 26, 40, 0, time.UTC)},
+		{"2020-09-13 14:26:40 Europe/Paris", time.Date(2020, 9, 13, 12, 26, 40, 0, time.UTC)},
+	}
+	for _, d := range pacificDates {
+		tests = append(tests, struct {
+			in   string
+			want time.Time
+		}{d.wall + " America/Los_Angeles", d.want})
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDate(tt.in)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("ParseDate(%q) = %v, %v; want %v", tt.in, got.UTC(), err, tt.want)
+		}
+	}
+
+	for _, in := range []string{
+		"",
+		"2020-09-13T12:26:40Z",
+		"2020-09-13 12:26:40 Mars/Olympus_Mons",
+		"2020-09-13 12:26 Etc/GMT",
+		"13/09/2020 05:26:40 America/Los_Angeles",
+	} {
+		if got, err := ParseDate(in); err == nil {
+			t.Errorf("ParseDate(%q) = %v; want an error", in, got)
+		}
+	}
+}
+
+func TestPacificOffsetFallback(t *testing.T) {
+	for _, d := range pacificDates {
+		wall, err := time.ParseInLocation(appleDateLayout, d.wall, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := wall.Add(-pacificOffset(wall)); !got.Equal(d.want) {
+			t.Errorf("%s Pacific Time without time zone data = %v; want %v", d.wall, got, d.want)
+		}
+	}
+
+	// Agrees with the time zone database, when there is one, except within the
+	// hours skipped or repeated by the transitions.
+	loc, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skip("no time zone data:", err)
+	}
+	for at := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC); at.Year() < 2026; at = at.Add(time.Hour) {
+		wall := time.Date(at.Year(), at.Month(), at.Day(), at.Hour(), 0, 0, 0, time.UTC)
+		want := time.Date(at.Year(), at.Month(), at.Day(), at.Hour(), 0, 0, 0, loc)
+		if want.Hour() != at.Hour() || want.Add(-time.Hour).In(loc).Hour() == at.Hour() {
+			continue
+		}
+		if got := wall.Add(-pacificOffset(wall)); !got.Equal(want) {
+			t.Fatalf("%s Pacific Time without time zone data = %v; want %v", wall.Format(appleDateLayout), got, want.UTC())
+		}
+	}
+}
+
+func TestCheckDates(t *testing.T) {
+	tests := []struct {
+		name string
+		info string
+		want []string // Paths of the mismatches.
+	}{
+		{
+			name: "consistent",
+			info: `"expires_date":"2020-06-19 18:40:00 Etc/GMT","expires_date_ms":"1592592000123","expires_date_pst":"2020-06-19 11:40:00 America/Los_Angeles"`,
+		},
+		{
+			name: "absent variants",
+			info: `"expires_date_ms":"1592592000000","expires_date_pst":"2020-06-19 11:40:00 America/Los_Angeles"`,
+		},
+		{
+			name: "tampered ms",
+			info: `"expires_date":"2020-06-19 18:40:00 Etc/GMT","expires_date_ms":"1624128000000","expires_date_pst":"2020-06-19 11:40:00 America/Los_Angeles"`,
+			want: []string{"latest_receipt_info[0].expires_date"},
+		},
+		{
+			name: "tampered pst",
+			info: `"expires_date":"2020-06-19 18:40:00 Etc/GMT","expires_date_ms":"1592592000000","expires_date_pst":"2021-06-19 11:40:00 America/Los_Angeles"`,
+			want: []string{"latest_receipt_info[0].expires_date"},
+		},
+		{
+			name: "a second off",
+			info: `"purchase_date":"2020-06-19 18:40:01 Etc/GMT","purchase_date_ms":"1592592000999"`,
+			want: []string{"latest_receipt_info[0].purchase_date"},
+		},
+		{
+			name: "unparseable",
+			info: `"expires_date":"tomorrow","expires_date_ms":"1592592000000"`,
+			want: []string{"latest_receipt_info[0].expires_date"},
+		},
+	}
+	for _, tt := range tests {
+		var resp ReceiptResponse
+		data := `{"receipt":{"request_date":"2020-06-19 18:40:00 Etc/GMT","request_date_ms":"1592592000000"},"latest_receipt_info":[{` + tt.info + `}]}`
+		if err := json.Unmarshal([]byte(data), &resp); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		var got []string
+		for _, m := range resp.CheckDates() {
+			got = append(got, m.Path)
+			if m.Err == nil || m.Error() == "" {
+				t.Errorf("%s: mismatch %s has no error", tt.name, m.Path)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: CheckDates() = %q; want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNotificationCheckDates(t *testing.T) {
+	var n Notification
+	data := `{"auto_renew_status_change_date":"2020-06-19 18:40:00 Etc/GMT","auto_renew_status_change_date_ms":"1592592000000",` +
+		`"unified_receipt":{"latest_receipt_info":[{"purchase_date":"2020-06-19 18:40:00 Etc/GMT","purchase_date_ms":"1592595600000"}]}}`
+	if err := json.Unmarshal([]byte(data), &n); err != nil {
+		t.Fatal(err)
+	}
+
+	mismatches := n.CheckDates()
+	if len(mismatches) != 1 || mismatches[0].Path != "unified_receipt.latest_receipt_info[0].purchase_date" {
+		t.Errorf("CheckDates() = %+v; want a mismatch of the unified receipt's purchase date", mismatches)
+	}
+}
+
+func BenchmarkCheckDates(b *testing.B) {
+	var resp ReceiptResponse
+	if err := json.Unmarshal(largeResponseBody(500), &resp); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		resp.CheckDates()
+	}
+}
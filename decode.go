@@ -0,0 +1,128 @@
+package storekit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultMaxResponseSize is the largest App Store response body a client reads
+// unless configured otherwise with WithMaxResponseSize.
+const DefaultMaxResponseSize = 16 << 20 // 16 MiB
+
+// ErrResponseTooLarge is wrapped in a *DecodeError when the App Store response
+// body exceeds the client's maximum response size.
+var ErrResponseTooLarge = errors.New("app store response too large")
+
+// decodeResponse reads at most maxSize bytes of an App Store response body and
+// decodes it into a ReceiptResponse, ignoring control characters (the App Store
+// has been seen sending them unescaped within strings). The raw body is
+// returned as read. sizeHint is the expected size of the body, or -1 if
+// unknown.
+func decodeResponse(body io.Reader, sizeHint, maxSize int64) ([]byte, *ReceiptResponse, error) {
+	raw, err := readBody(body, sizeHint, maxSize)
+	switch {
+	case err == ErrResponseTooLarge:
+		return raw, nil, &DecodeError{Err: ErrResponseTooLarge}
+	case err != nil:
+		return raw, nil, errors.Wrap(err, "could not read app store response")
+	}
+
+	resp := &ReceiptResponse{}
+	if err := json.Unmarshal(dropControlChars(raw), resp); err != nil {
+		return raw, nil, &DecodeError{Err: err}
+	}
+
+	return raw, resp, nil
+}
+
+// readBody reads r to the end into a single buffer, sized up front when the
+// size of the body is known. It fails with ErrResponseTooLarge once more than
+// maxSize bytes have been read.
+func readBody(r io.Reader, sizeHint, maxSize int64) ([]byte, error) {
+	var buf bytes.Buffer
+	if sizeHint > 0 && sizeHint <= maxSize {
+		// ReadFrom wants MinRead bytes of room to see the end of the body.
+		buf.Grow(int(sizeHint) + bytes.MinRead)
+	}
+
+	limited := &limitedReader{r: r, n: maxSize}
+	_, err := buf.ReadFrom(limited)
+	return buf.Bytes(), err
+}
+
+// limitedReader reads from r until n bytes have been read, then fails with
+// ErrResponseTooLarge.
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	// Read one byte past the limit to tell a body of exactly n bytes from a
+	// larger one.
+	if int64(len(p))-1 > l.n {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	if int64(n) > l.n {
+		return int(l.n), ErrResponseTooLarge
+	}
+	l.n -= int64(n)
+	return n, err
+}
+
+// dropControlChars returns data without the Unicode control characters (as in
+// unicode.IsControl) of its UTF-8 text. Data without any is returned as is, so
+// the common case costs a scan but no copy. Invalid UTF-8 is kept.
+func dropControlChars(data []byte) []byte {
+	i := indexControlChar(data)
+	if i < 0 {
+		return data
+	}
+
+	out := make([]byte, i, len(data))
+	copy(out, data[:i])
+	for i < len(data) {
+		if c := data[i]; c < utf8.RuneSelf {
+			if c >= 0x20 && c != 0x7f {
+				out = append(out, c)
+			}
+			i++
+			continue
+		}
+
+		r, size := utf8.DecodeRune(data[i:])
+		if !unicode.IsControl(r) {
+			out = append(out, data[i:i+size]...)
+		}
+		i += size
+	}
+	return out
+}
+
+// indexControlChar returns the index of the first control character in data,
+// or -1 if there is none.
+func indexControlChar(data []byte) int {
+	for i := 0; i < len(data); {
+		c := data[i]
+		if c < utf8.RuneSelf {
+			if c < 0x20 || c == 0x7f {
+				return i
+			}
+			i++
+			continue
+		}
+
+		r, size := utf8.DecodeRune(data[i:])
+		if unicode.IsControl(r) {
+			return i
+		}
+		i += size
+	}
+	return -1
+}
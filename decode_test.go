@@ -0,0 +1,153 @@
+package storekit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"testing"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// largeResponseBody returns an App Store response with n latest receipt info
+// entries, like those of long-running subscriptions.
+func largeResponseBody(n int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`{"status":0,"environment":"Production","receipt":{"bundle_id":"com.example.app","in_app":[]},"latest_receipt_info":[`)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		ms := int64(1590000000000) + int64(i)*2592000000
+		fmt.Fprintf(&buf, `{"quantity":"1","product_id":"com.example.app.monthly","transaction_id":"10000%07d","original_transaction_id":"1000000000000001",`+
+			`"purchase_date":"2020-05-20 18:40:00 Etc/GMT","purchase_date_ms":"%d","purchase_date_pst":"2020-05-20 11:40:00 America/Los_Angeles",`+
+			`"original_purchase_date":"2020-05-20 18:40:00 Etc/GMT","original_purchase_date_ms":"1590000000000","original_purchase_date_pst":"2020-05-20 11:40:00 America/Los_Angeles",`+
+			`"expires_date":"2020-06-19 18:40:00 Etc/GMT","expires_date_ms":"%d","expires_date_pst":"2020-06-19 11:40:00 America/Los_Angeles",`+
+			`"web_order_line_item_id":"2000%07d","is_trial_period":"false","is_in_intro_offer_period":"false","in_app_ownership_type":"PURCHASED","subscription_group_identifier":"20600000"}`,
+			i, ms, ms+2592000000, i)
+	}
+	buf.WriteString(`],"latest_receipt":"TUlJVE5BWUpLb1pJaHZjTkFRY0NvSUlUT1RDQ0V6VUNBUUV4Q3pBSkJnVXJEZ01DR2dVQU1JSURJZ1lKS29aSWh2Y05BUWNCb0lJREV3U0NBdzh4Z2dNTE1Bb0NBUWdDQVFFRUFoWUFNQW9DQVJRQ0FRRUVBZ3dBTUFzQ0FRRUNBUUVFQXdJQkFEQUxBZ0VEQWdFQkJBTU1BVEV3Q3dJQkN3SUJBUVFEQWdFQU1Bc0NBUThDQVFFRUF3SUJBREFMQWdFUUFnRUJCQU1DQVFBd0N3SUJHUUlCQVFRREFnRURNQXdDQVFvQ0FRRUVCQllDTkNzd0RBSUJEZ0lCQVFRRUFnSUEzVEFOQWdFTkFnRUJCQVVDQXdIOVBEQU5BZ0VUQWdFQkJBVU1BekV1TURBT0FnRUpBZ0VCQkFZQ0JGQXlOVE13R0FJQkJBSUJBZ1FRZ1V6cXN0NzA="}`)
+	return buf.Bytes()
+}
+
+// decodeResponseReadAll is the decoding of the client before streaming: read
+// the whole body, copy it without control characters, then unmarshal.
+func decodeResponseReadAll(body []byte) (*ReceiptResponse, error) {
+	raw, err := ioutil.ReadAll(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	resp := &ReceiptResponse{}
+	err = json.Unmarshal(bytes.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, raw), resp)
+	return resp, err
+}
+
+func TestDecodeResponse(t *testing.T) {
+	body := largeResponseBody(3)
+
+	raw, resp, err := decodeResponse(bytes.NewReader(body), int64(len(body)), DefaultMaxResponseSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(raw, body) {
+		t.Error("raw body differs from the one read")
+	}
+	want, err := decodeResponseReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(resp) != fmt.Sprint(want) {
+		t.Errorf("decodeResponse() = %+v; want %+v", resp, want)
+	}
+}
+
+func TestDecodeResponseDropsControlChars(t *testing.T) {
+	body := []byte("{\"status\":0,\"latest_receipt_info\":[{\"product_id\":\"com.exam\x00ple\u0085.app\",\n\"transaction_id\":\"1\"}]}")
+
+	for _, sizeHint := range []int64{int64(len(body)), -1} {
+		raw, resp, err := decodeResponse(bytes.NewReader(body), sizeHint, DefaultMaxResponseSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(raw, body) {
+			t.Errorf("raw body = %q; want %q", raw, body)
+		}
+		if got := resp.LatestReceiptInfo[0].ProductId; got != "com.example.app" {
+			t.Errorf("product id = %q; want com.example.app", got)
+		}
+	}
+}
+
+func TestDecodeResponseTooLarge(t *testing.T) {
+	body := largeResponseBody(10)
+
+	for _, sizeHint := range []int64{int64(len(body)), -1} {
+		_, _, err := decodeResponse(bytes.NewReader(body), sizeHint, int64(len(body)-1))
+		if _, ok := err.(*DecodeError); !ok || !errors.Is(err, ErrResponseTooLarge) {
+			t.Errorf("decodeResponse() error = %v; want ErrResponseTooLarge", err)
+		}
+
+		for _, maxSize := range []int64{int64(len(body)), math.MaxInt64} {
+			if _, _, err := decodeResponse(bytes.NewReader(body), sizeHint, maxSize); err != nil {
+				t.Errorf("decodeResponse() with max size %d error = %v", maxSize, err)
+			}
+		}
+	}
+}
+
+func TestDropControlChars(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{`{"a":"b"}`, `{"a":"b"}`},
+		{"a\x00b\x1fc\x7fd\n", "abcd"},
+		{"\u0080café\u009f", "café"},
+		{"\xffin\x01valid\xc2", "\xffinvalid\xc2"},
+	}
+	for _, tt := range tests {
+		if got := string(dropControlChars([]byte(tt.in))); got != tt.want {
+			t.Errorf("dropControlChars(%q) = %q; want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func BenchmarkDecodeResponse(b *testing.B) {
+	body := largeResponseBody(500)
+
+	b.Run("ReadAll", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(body)))
+		for i := 0; i < b.N; i++ {
+			if _, err := decodeResponseReadAll(body); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	for _, bc := range []struct {
+		name     string
+		sizeHint int64
+	}{
+		{"ContentLength", int64(len(body))},
+		{"Chunked", -1},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(body)))
+			for i := 0; i < b.N; i++ {
+				if _, _, err := decodeResponse(bytes.NewReader(body), bc.sizeHint, DefaultMaxResponseSize); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package storekit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Deduplicator collapses concurrent verifications of the same receipt into a
+// single App Store call and fans the result out to all callers. Requests are the
+// same if their receipt data, password and exclusion flag are.
+//
+// Callers waiting on the same call get their own copy of the VerifyResult with
+// Shared set, but the Response and Body in it are shared and must not be
+// modified.
+//
+// The shared call carries the values of the context that started it, but not
+// its deadline or cancellation: each caller stops waiting when its own context
+// is done, and the call is canceled once no caller is waiting anymore. The
+// sandbox policy of the verifier is applied to each caller with its own context.
+type Deduplicator struct {
+	verifier Verifier
+
+	mu    sync.Mutex
+	calls map[string]*dedupCall
+}
+
+type dedupCall struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiters int
+
+	result *VerifyResult
+	err    error
+}
+
+// NewDeduplicator returns a Deduplicator verifying receipts with verifier.
+func NewDeduplicator(verifier Verifier) *Deduplicator {
+	return &Deduplicator{
+		verifier: verifier,
+		calls:    map[string]*dedupCall{},
+	}
+}
+
+func (d *Deduplicator) Verify(ctx context.Context, req *ReceiptRequest) (*VerifyResult, error) {
+	if req == nil {
+		return &VerifyResult{SharedSecretIndex: -1}, ErrNilRequest
+	}
+
+	key := requestKey(req)
+
+	d.mu.Lock()
+	call, ok := d.calls[key]
+	if !ok {
+		callCtx, cancel := context.WithCancel(deferSandboxPolicy(detachedContext{ctx}, d.verifier))
+		call = &dedupCall{
+			done:   make(chan struct{}),
+			cancel: cancel,
+		}
+		d.calls[key] = call
+
+		// The caller's request may be modified once it returns.
+		callReq := *req
+		go d.do(callCtx, key, call, &callReq)
+	}
+	call.waiters++
+	shared := ok
+	d.mu.Unlock()
+
+	select {
+	case <-call.done:
+		d.mu.Lock()
+		shared = shared || call.waiters > 1
+		d.mu.Unlock()
+
+		result := &VerifyResult{}
+		if call.result != nil {
+			*result = *call.result
+		}
+		result.Shared = shared
+
+		err := call.err
+		if err == nil {
+			err = checkSandboxPolicy(ctx, d.verifier, req, result)
+		}
+		return result, err
+
+	case <-ctx.Done():
+		d.mu.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			call.cancel()
+			if d.calls[key] == call {
+				delete(d.calls, key)
+			}
+		}
+		d.mu.Unlock()
+
+		return &VerifyResult{}, ctx.Err()
+	}
+}
+
+func (d *Deduplicator) checkSandboxPolicy(ctx context.Context, req *ReceiptRequest, result *VerifyResult) error {
+	return checkSandboxPolicy(ctx, d.verifier, req, result)
+}
+
+func (d *Deduplicator) do(ctx context.Context, key string, call *dedupCall, req *ReceiptRequest) {
+	call.result, call.err = d.verifier.Verify(ctx, req)
+
+	d.mu.Lock()
+	if d.calls[key] == call {
+		delete(d.calls, key)
+	}
+	d.mu.Unlock()
+
+	close(call.done)
+	call.cancel()
+}
+
+// detachedContext carries the values of its parent, but is never done.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
@@ -0,0 +1,111 @@
+package storekit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDeduplicatorFansOut(t *testing.T) {
+	const callers = 5
+
+	store := newAppStore(t, ReceiptResponseStatusOK)
+	store.Hold = make(chan struct{})
+	c, err := NewClient(WithProductionURL(store.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := NewDeduplicator(c)
+
+	var wg sync.WaitGroup
+	results := make([]*VerifyResult, callers)
+	errs := make([]error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Each caller has its own copy of the request.
+			req := &ReceiptRequest{ReceiptData: []byte("receipt")}
+			results[i], errs[i] = d.Verify(context.Background(), req)
+		}(i)
+	}
+	<-store.Received
+	waitForWaiters(t, d, &ReceiptRequest{ReceiptData: []byte("receipt")}, callers)
+	close(store.Hold)
+	wg.Wait()
+
+	for i := 0; i < callers; i++ {
+		if errs[i] != nil || !results[i].Shared || results[i].Response != results[0].Response {
+			t.Errorf("caller %d: Verify() = %+v, %v; want the shared result", i, results[i], errs[i])
+		}
+	}
+	if got := len(store.requests()); got != 1 {
+		t.Errorf("App Store got %d requests; want 1", got)
+	}
+
+	// Later calls aren't deduplicated with finished ones.
+	result, err := d.Verify(context.Background(), &ReceiptRequest{ReceiptData: []byte("receipt")})
+	if err != nil || result.Shared {
+		t.Errorf("Verify() after the shared call = %+v, %v; want a result of its own", result, err)
+	}
+	if got := len(store.requests()); got != 2 {
+		t.Errorf("App Store got %d requests; want 2", got)
+	}
+}
+
+func TestDeduplicatorCancelsWhenLastWaiterLeaves(t *testing.T) {
+	store := newAppStore(t, ReceiptResponseStatusOK)
+	store.Hold = make(chan struct{})
+	c, err := NewClient(WithProductionURL(store.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := NewDeduplicator(c)
+	req := &ReceiptRequest{ReceiptData: []byte("receipt")}
+
+	verify := func(ctx context.Context) <-chan error {
+		done := make(chan error, 1)
+		go func() {
+			_, err := d.Verify(ctx, req)
+			done <- err
+		}()
+		return done
+	}
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	done1 := verify(ctx1)
+	<-store.Received
+	done2 := verify(ctx2)
+	waitForWaiters(t, d, req, 2)
+
+	// The first caller leaving doesn't cancel the call the second waits on.
+	cancel1()
+	if err := <-done1; err != context.Canceled {
+		t.Errorf("first caller: Verify() error = %v; want context.Canceled", err)
+	}
+	waitForWaiters(t, d, req, 1)
+	select {
+	case <-store.Canceled:
+		t.Fatal("call canceled while a caller still waits")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// The last one leaving does.
+	cancel2()
+	if err := <-done2; err != context.Canceled {
+		t.Errorf("second caller: Verify() error = %v; want context.Canceled", err)
+	}
+	select {
+	case <-store.Canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("call not canceled once no caller waits")
+	}
+
+	// A new caller starts a new call.
+	close(store.Hold)
+	if _, err := d.Verify(context.Background(), req); err != nil {
+		t.Errorf("Verify() after cancellation error = %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package storekit
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// TransportError is returned when the App Store server could not be reached or
+// its response could not be read.
+type TransportError struct {
+	// URL is the verification endpoint that was dialed.
+	URL string
+
+	// Err is the underlying network error.
+	Err error
+}
+
+func (e *TransportError) Error() string {
+	return "could not connect to app store server: " + e.Err.Error()
+}
+
+func (e *TransportError) Cause() error  { return e.Err }
+func (e *TransportError) Unwrap() error { return e.Err }
+
+// HTTPStatusError is returned when the App Store server answers with an HTTP
+// status other than 200 OK.
+type HTTPStatusError struct {
+	// URL is the verification endpoint that answered.
+	URL string
+
+	// StatusCode is the HTTP status code, e.g. 503.
+	StatusCode int
+
+	// Status is the HTTP status line, e.g. "503 Service Unavailable".
+	Status string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return "app store http error (" + e.Status + ")"
+}
+
+// DecodeError is returned when the App Store response body is not a valid
+// receipt response.
+type DecodeError struct {
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return "could not unmarshal app store response: " + e.Err.Error()
+}
+
+func (e *DecodeError) Cause() error  { return e.Err }
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// StatusError is returned when the App Store decoded the request but answered
+// with a status other than ReceiptResponseStatusOK. The response itself is
+// still returned alongside the error.
+type StatusError struct {
+	// Status is the status of the app receipt as reported by the App Store.
+	Status ReceiptResponseStatus
+
+	// IsRetryable is the is-retryable flag of the response. Only meaningful for
+	// status codes 21100-21199.
+	IsRetryable bool
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("receipt rejected by app store with status %d", e.Status)
+}
+
+// Is makes errors.Is(err, &StatusError{Status: s}) match any StatusError with
+// status s.
+func (e *StatusError) Is(target error) bool {
+	t, ok := target.(*StatusError)
+	return ok && t.Status == e.Status
+}
+
+func (e *StatusError) retryable() bool {
+	switch e.Status {
+	case ReceiptResponseStatusDataMalformed,
+		ReceiptResponseStatusReceiptServerUnavailable,
+		ReceiptResponseStatusBadAccess:
+		return true
+	}
+
+	if e.Status >= 21100 && e.Status <= 21199 {
+		return e.IsRetryable
+	}
+
+	return false
+}
+
+func (e *StatusError) misconfiguration() bool {
+	switch e.Status {
+	case ReceiptResponseStatusAppStoreCannotRead,
+		ReceiptResponseStatusSharedSecretDoesNotMatch,
+		ReceiptResponseStatusSandboxReceiptSentToProduction,
+		ReceiptResponseStatusProductionReceiptSentToSandbox:
+		return true
+	}
+	return false
+}
+
+func statusError(resp *ReceiptResponse) error {
+	if resp.Status == ReceiptResponseStatusOK {
+		return nil
+	}
+	return &StatusError{
+		Status:      resp.Status,
+		IsRetryable: resp.IsRetryable,
+	}
+}
+
+// IsRetryable reports whether err is a transient failure that may succeed if
+// the receipt is verified again later: a network error, a non-200 HTTP
+// response, an App Store status asking to try again (21002, 21005, 21009, and
+// 21100-21199 with is-retryable set), or an open circuit breaker. Context
+// cancellation is never retryable.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if errors.Is(err, ErrCircuitOpen) {
+		return true
+	}
+
+	var transportErr *TransportError
+	if errors.As(err, &transportErr) {
+		return true
+	}
+
+	var httpErr *HTTPStatusError
+	if errors.As(err, &httpErr) {
+		return true
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.retryable()
+	}
+
+	return false
+}
+
+// IsMisconfiguration reports whether err is caused by the way the receipt was
+// sent rather than by the receipt itself, e.g. a shared secret mismatch (21004)
+// or a receipt sent to the wrong environment (21007, 21008).
+func IsMisconfiguration(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.misconfiguration()
+}
+
+// IsPermanent reports whether err is a rejection of the receipt or its response
+// that will not change on retry, e.g. a receipt that could not be authenticated
+// (21003), a response that could not be decoded, a receipt of an app a Router
+// doesn't serve, or a sandbox receipt rejected by policy.
+func IsPermanent(err error) bool {
+	var decodeErr *DecodeError
+	if errors.As(err, &decodeErr) {
+		return true
+	}
+
+	var mismatchErr *BundleIDMismatchError
+	if errors.Is(err, ErrUnknownBundleID) || errors.As(err, &mismatchErr) || errors.Is(err, ErrSandboxReceiptRejected) {
+		return true
+	}
+
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && !statusErr.retryable() && !statusErr.misconfiguration()
+}
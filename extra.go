@@ -0,0 +1,211 @@
+package storekit
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Apple adds fields to its responses regularly. The models below keep the keys
+// they don't know in an Extra map, and write them back when marshaled, so that
+// no field is lost from stored copies and new fields can be read before this
+// package catches up. Known fields are written back as the models encode them,
+// which may differ from the App Store's encoding (see Timestamp).
+
+// UnmarshalJSON implements json.Unmarshaler, keeping unknown keys in Extra.
+func (r *ReceiptResponse) UnmarshalJSON(data []byte) error {
+	type receiptResponse ReceiptResponse // Drops the methods.
+	return unmarshalWithExtra(data, (*receiptResponse)(r), &r.Extra)
+}
+
+// MarshalJSON implements json.Marshaler, writing back the keys in Extra.
+func (r ReceiptResponse) MarshalJSON() ([]byte, error) {
+	type receiptResponse ReceiptResponse
+	return marshalWithExtra(receiptResponse(r), r.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, keeping unknown keys in Extra.
+func (i *LatestReceiptInfo) UnmarshalJSON(data []byte) error {
+	type latestReceiptInfo LatestReceiptInfo
+	return unmarshalWithExtra(data, (*latestReceiptInfo)(i), &i.Extra)
+}
+
+// MarshalJSON implements json.Marshaler, writing back the keys in Extra.
+func (i LatestReceiptInfo) MarshalJSON() ([]byte, error) {
+	type latestReceiptInfo LatestReceiptInfo
+	return marshalWithExtra(latestReceiptInfo(i), i.Extra)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, keeping unknown keys in Extra.
+func (i *PendingRenewalInfo) UnmarshalJSON(data []byte) error {
+	type pendingRenewalInfo PendingRenewalInfo
+	return unmarshalWithExtra(data, (*pendingRenewalInfo)(i), &i.Extra)
+}
+
+// MarshalJSON implements json.Marshaler, writing back the keys in Extra.
+func (i PendingRenewalInfo) MarshalJSON() ([]byte, error) {
+	type pendingRenewalInfo PendingRenewalInfo
+	return marshalWithExtra(pendingRenewalInfo(i), i.Extra)
+}
+
+// unmarshalWithExtra decodes data into v, a pointer to a struct, and stores the
+// keys of data that match none of its fields in extra.
+//
+// Once data is known to be valid, its keys are found by a scan that skips over
+// the values without decoding them, so only unknown keys cost allocations.
+func unmarshalWithExtra(data []byte, v interface{}, extra *map[string]json.RawMessage) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return err
+	}
+
+	known := knownFields(reflect.TypeOf(v).Elem())
+	*extra = nil
+
+	i := skipSpace(data, 0)
+	if i == len(data) || data[i] != '{' {
+		return nil // null
+	}
+	for i = skipSpace(data, i+1); i < len(data) && data[i] != '}'; {
+		keyEnd := skipValue(data, i)
+		key := data[i+1 : keyEnd-1]
+		valueStart := skipSpace(data, skipSpace(data, keyEnd)+1) // After the colon.
+		valueEnd := skipValue(data, valueStart)
+
+		if !known[string(key)] && !known[foldKey(data[i:keyEnd])] {
+			if *extra == nil {
+				*extra = map[string]json.RawMessage{}
+			}
+			name := string(key)
+			if bytes.IndexByte(key, '\\') >= 0 {
+				_ = json.Unmarshal(data[i:keyEnd], &name)
+			}
+			(*extra)[name] = append(json.RawMessage(nil), data[valueStart:valueEnd]...)
+		}
+
+		i = skipSpace(data, valueEnd)
+		if i < len(data) && data[i] == ',' {
+			i = skipSpace(data, i+1)
+		}
+	}
+	return nil
+}
+
+// foldKey returns the lowercased name of a quoted JSON key, to be matched
+// against knownFields like encoding/json does.
+func foldKey(quoted []byte) string {
+	var name string
+	_ = json.Unmarshal(quoted, &name)
+	return strings.ToLower(name)
+}
+
+// skipSpace returns the index of the first byte of data from i on that isn't
+// JSON whitespace.
+func skipSpace(data []byte, i int) int {
+	for i < len(data) {
+		switch data[i] {
+		case ' ', '\t', '\n', '\r':
+			i++
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// skipValue returns the index right after the valid JSON value starting at
+// index i of data.
+func skipValue(data []byte, i int) int {
+	depth := 0
+	for ; i < len(data); i++ {
+		switch data[i] {
+		case '"':
+			for i++; i < len(data) && data[i] != '"'; i++ {
+				if data[i] == '\\' {
+					i++
+				}
+			}
+		case '{', '[':
+			depth++
+			continue
+		case '}', ']':
+			depth--
+		case ',', ':', ' ', '\t', '\n', '\r':
+			if depth == 0 {
+				return i
+			}
+			continue
+		default:
+			// Within a number or a literal.
+			if depth == 0 && i+1 < len(data) && isDelimiter(data[i+1]) {
+				return i + 1
+			}
+			continue
+		}
+		if depth == 0 {
+			return i + 1
+		}
+	}
+	return i
+}
+
+func isDelimiter(c byte) bool {
+	switch c {
+	case ',', ':', '}', ']', ' ', '\t', '\n', '\r':
+		return true
+	}
+	return false
+}
+
+// marshalWithExtra encodes v, a struct, and appends the keys of extra it
+// doesn't already have, in sorted order.
+func marshalWithExtra(v interface{}, extra map[string]json.RawMessage) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+
+	known := knownFields(reflect.TypeOf(v))
+	keys := make([]string, 0, len(extra))
+	for key := range extra {
+		if !known[strings.ToLower(key)] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	buf.Grow(len(data) + 64*len(keys))
+	buf.Write(data[:len(data)-1]) // Without the closing brace.
+	for _, key := range keys {
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(extra[key])
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+var knownFieldsCache sync.Map // reflect.Type -> map[string]bool
+
+// knownFields returns the JSON keys of the fields of struct type t, lowercased
+// since encoding/json matches keys case-insensitively.
+func knownFields(t reflect.Type) map[string]bool {
+	if known, ok := knownFieldsCache.Load(t); ok {
+		return known.(map[string]bool)
+	}
+
+	known := map[string]bool{}
+	for key := range jsonFields(t) {
+		known[key] = true
+	}
+
+	knownFieldsCache.Store(t, known)
+	return known
+}
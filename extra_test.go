@@ -0,0 +1,81 @@
+package storekit
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestUnmarshalKeepsUnknownKeys(t *testing.T) {
+	data := []byte(`{"status":0,"new_field":{"a":[1,"}"]},"latest_receipt_info":[` +
+		`{"product_id":"p","Offer_Code_Ref_Name":"x\"y","quantity":"1","is_trial_period":"true","new_flag":true}` +
+		`],"pending_renewal_info":[{"product_id":"p","price_consent_status":"1"}],"Status":0}`)
+
+	var resp ReceiptResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	wantExtra := map[string]json.RawMessage{"new_field": json.RawMessage(`{"a":[1,"}"]}`)}
+	if !reflect.DeepEqual(resp.Extra, wantExtra) {
+		t.Errorf("ReceiptResponse.Extra = %s; want %s", resp.Extra, wantExtra)
+	}
+	info := resp.LatestReceiptInfo[0]
+	if info.ProductId != "p" || info.Quantity != 1 || !info.IsTrialPeriod.Bool() {
+		t.Errorf("LatestReceiptInfo = %+v", info)
+	}
+	wantExtra = map[string]json.RawMessage{"new_flag": json.RawMessage(`true`)}
+	if !reflect.DeepEqual(info.Extra, wantExtra) {
+		t.Errorf("LatestReceiptInfo.Extra = %s; want %s", info.Extra, wantExtra)
+	}
+	if extra := resp.PendingRenewalInfo[0].Extra; extra != nil {
+		t.Errorf("PendingRenewalInfo.Extra = %s; want none", extra)
+	}
+
+	out, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var again ReceiptResponse
+	if err := json.Unmarshal(out, &again); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again, resp) {
+		t.Errorf("round trip = %+v; want %+v", again, resp)
+	}
+}
+
+func BenchmarkUnmarshalWithExtra(b *testing.B) {
+	var body struct {
+		LatestReceiptInfo json.RawMessage `json:"latest_receipt_info"`
+	}
+	if err := json.Unmarshal(largeResponseBody(500), &body); err != nil {
+		b.Fatal(err)
+	}
+	data := []byte(body.LatestReceiptInfo)
+
+	// latestReceiptInfo has no methods, so unknown keys are dropped.
+	type latestReceiptInfo LatestReceiptInfo
+
+	b.Run("WithoutExtra", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			var infos []latestReceiptInfo
+			if err := json.Unmarshal(data, &infos); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("WithExtra", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			var infos []LatestReceiptInfo
+			if err := json.Unmarshal(data, &infos); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package storekit
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// Flag is a boolean field of App Store responses and notifications, such as
+// is_trial_period or a notification's auto_renew_status. Apple encodes these
+// as "true" and "false" in some fields and as "0" and "1" in others.
+//
+// Models hold flags by pointer, nil when the field is absent, so that they are
+// omitted when encoded. Decoded flags are encoded back exactly as received;
+// those made with FlagOf are encoded as "true" or "false". The methods other
+// than MarshalJSON accept a nil flag.
+type Flag struct {
+	set   bool
+	value bool
+	raw   string // The JSON decoded, empty for flags made with FlagOf.
+}
+
+// FlagOf returns a flag set to b.
+func FlagOf(b bool) *Flag {
+	return &Flag{set: true, value: b}
+}
+
+// Bool returns the value of the flag. An absent flag is false; use IsSet to
+// tell it apart from one that is explicitly false.
+func (f *Flag) Bool() bool {
+	return f != nil && f.value
+}
+
+// IsSet reports whether the flag has a value. A flag decoded from an empty
+// string has none.
+func (f *Flag) IsSet() bool {
+	return f != nil && f.set
+}
+
+// String returns the flag as Apple sent it, without quotes, e.g. "true" or "0".
+// Flags made with FlagOf are "true" or "false", absent ones empty.
+func (f *Flag) String() string {
+	switch {
+	case f == nil:
+		return ""
+	case f.raw != "":
+		text := f.raw
+		if text[0] == '"' {
+			_ = json.Unmarshal([]byte(f.raw), &text)
+		}
+		return text
+	case f.set && f.value:
+		return "true"
+	case f.set:
+		return "false"
+	default:
+		return ""
+	}
+}
+
+// MarshalJSON implements json.Marshaler, encoding the flag as it was decoded,
+// or as "true" or "false". A zero Flag is encoded as null.
+func (f Flag) MarshalJSON() ([]byte, error) {
+	switch {
+	case f.raw != "":
+		return []byte(f.raw), nil
+	case !f.set:
+		return []byte("null"), nil
+	case f.value:
+		return []byte(`"true"`), nil
+	default:
+		return []byte(`"false"`), nil
+	}
+}
+
+// UnmarshalJSON implements json.Unmarshaler, accepting "true", "false", "1"
+// and "0" either as strings or as JSON booleans and numbers. An empty string
+// decodes as a flag without a value.
+func (f *Flag) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if string(data) == "null" {
+		*f = Flag{}
+		return nil
+	}
+
+	text := string(data)
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+	}
+
+	switch text {
+	case "true", "1":
+		*f = Flag{set: true, value: true, raw: string(data)}
+	case "false", "0":
+		*f = Flag{set: true, value: false, raw: string(data)}
+	case "":
+		*f = Flag{raw: string(data)}
+	default:
+		return errors.Errorf("invalid flag %s", data)
+	}
+	return nil
+}
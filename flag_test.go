@@ -0,0 +1,107 @@
+package storekit
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFlagRoundTrip(t *testing.T) {
+	tests := []struct {
+		json        string
+		set, value  bool
+		stringValue string
+	}{
+		{`"true"`, true, true, "true"},
+		{`"false"`, true, false, "false"},
+		{`"1"`, true, true, "1"},
+		{`"0"`, true, false, "0"},
+		{`true`, true, true, "true"},
+		{`0`, true, false, "0"},
+		{`""`, false, false, ""},
+	}
+	for _, tt := range tests {
+		data := []byte(`{"is_trial_period":` + tt.json + `}`)
+
+		var info InAppPurchaseReceipt
+		if err := json.Unmarshal(data, &info); err != nil {
+			t.Errorf("Unmarshal(%s) error = %v", data, err)
+			continue
+		}
+		f := info.IsTrialPeriod
+		if f.IsSet() != tt.set || f.Bool() != tt.value || f.String() != tt.stringValue {
+			t.Errorf("Unmarshal(%s) = set %t, value %t, string %q; want %t, %t, %q",
+				data, f.IsSet(), f.Bool(), f.String(), tt.set, tt.value, tt.stringValue)
+		}
+
+		out, err := json.Marshal(info)
+		if err != nil {
+			t.Errorf("Marshal() error = %v", err)
+		} else if string(out) != string(data) {
+			t.Errorf("Marshal() = %s; want %s", out, data)
+		}
+	}
+}
+
+func TestFlagAbsent(t *testing.T) {
+	for _, data := range []string{`{}`, `{"is_trial_period":null}`} {
+		var info InAppPurchaseReceipt
+		if err := json.Unmarshal([]byte(data), &info); err != nil {
+			t.Fatal(err)
+		}
+		if f := info.IsTrialPeriod; f.IsSet() || f.Bool() || f.String() != "" {
+			t.Errorf("Unmarshal(%s) = %+v; want an absent flag", data, f)
+		}
+		if out, _ := json.Marshal(info); string(out) != `{}` {
+			t.Errorf("Marshal() = %s; want {}", out)
+		}
+	}
+}
+
+func TestFlagOf(t *testing.T) {
+	out, err := json.Marshal(InAppPurchaseReceipt{IsTrialPeriod: FlagOf(true), IsUpgraded: FlagOf(false)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"is_trial_period":"true","is_upgraded":"false"}`; string(out) != want {
+		t.Errorf("Marshal() = %s; want %s", out, want)
+	}
+
+	if out, _ := json.Marshal(&Flag{}); string(out) != `null` {
+		t.Errorf("Marshal(Flag{}) = %s; want null", out)
+	}
+}
+
+func TestFlagMarshalsAsValue(t *testing.T) {
+	var decoded Flag
+	if err := json.Unmarshal([]byte(`"1"`), &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		v    interface{}
+		want string
+	}{
+		{*FlagOf(true), `"true"`},
+		{decoded, `"1"`},
+		{Flag{}, `null`},
+		{map[string]Flag{"a": *FlagOf(false)}, `{"a":"false"}`},
+		{struct{ F *Flag }{}, `{"F":null}`},
+	}
+	for _, tt := range tests {
+		out, err := json.Marshal(tt.v)
+		if err != nil {
+			t.Errorf("Marshal(%#v) error = %v", tt.v, err)
+		} else if string(out) != tt.want {
+			t.Errorf("Marshal(%#v) = %s; want %s", tt.v, out, tt.want)
+		}
+	}
+}
+
+func TestFlagRejectsInvalidValues(t *testing.T) {
+	for _, data := range []string{`"yes"`, `2`, `"True"`, `{}`} {
+		var f Flag
+		if err := json.Unmarshal([]byte(data), &f); err == nil {
+			t.Errorf("Unmarshal(%s) = %+v; want an error", data, f)
+		}
+	}
+}
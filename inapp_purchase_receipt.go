@@ -14,7 +14,7 @@ type InAppPurchaseReceipt struct {
 	// milliseconds.This field is only present for refunded transactions.Use this
 	// time format for processing dates.
 	// information.
-	CancellationDateMs int64 `json:"cancellation_date_ms,string,omitempty"`
+	CancellationDateMs Timestamp `json:"cancellation_date_ms,omitempty"`
 
 	// The time Apple customer support canceled a transaction, or the time an
 	// auto-renewable subscription plan was upgraded, in the Pacific Time zone. This
@@ -35,7 +35,7 @@ type InAppPurchaseReceipt struct {
 
 	// The time a subscription expires or when it will renew, in UNIX epoch time
 	// format, in milliseconds.Use this time format for processing dates.
-	ExpiresDateMs int64 `json:"expires_date_ms,string,omitempty"`
+	ExpiresDateMs Timestamp `json:"expires_date_ms,omitempty"`
 
 	// The time a subscription expires or when it will renew, in the Pacific Time
 	// zone.
@@ -51,16 +51,16 @@ type InAppPurchaseReceipt struct {
 
 	// An indicator of whether an auto-renewable subscription is in the introductory
 	// price period.
-	IsInIntroOfferPeriod string `json:"is_in_intro_offer_period,omitempty"`
+	IsInIntroOfferPeriod *Flag `json:"is_in_intro_offer_period,omitempty"`
 
 	// An indication of whether a subscription is in the free trial period.
-	IsTrialPeriod string `json:"is_trial_period,omitempty"`
+	IsTrialPeriod *Flag `json:"is_trial_period,omitempty"`
 
 	// An indicator that a subscription has been canceled due to an upgrade. This
 	// field is only present for upgrade transactions.
 	//
 	// Although not documented, this field helps maintain compatibility with LatestReceiptInfo
-	IsUpgraded string `json:"is_upgraded,omitempty"`
+	IsUpgraded *Flag `json:"is_upgraded,omitempty"`
 
 	// The reference name of a subscription offer that you configured in App Store
 	// Connect. This field is present when a customer redeemed a subscription offer
@@ -81,7 +81,7 @@ type InAppPurchaseReceipt struct {
 	// same product ID. This value corresponds to the original transaction’s
 	// transactionDate property in StoreKit. Use this time format for processing
 	// dates.
-	OriginalPurchaseDateMs int64 `json:"original_purchase_date_ms,string,omitempty"`
+	OriginalPurchaseDateMs Timestamp `json:"original_purchase_date_ms,omitempty"`
 
 	// The time of the original in-app purchase, in the Pacific Time zone.
 	OriginalPurchaseDatePst string `json:"original_purchase_date_pst,omitempty"`
@@ -112,7 +112,7 @@ type InAppPurchaseReceipt struct {
 	// subscriptions, the time the App Store charged the user’s account for a
 	// subscription purchase or renewal after a lapse, in the UNIX epoch time
 	// format, in milliseconds. Use this time format for processing dates.
-	PurchaseDateMs int64 `json:"purchase_date_ms,string,omitempty"`
+	PurchaseDateMs Timestamp `json:"purchase_date_ms,omitempty"`
 
 	// The time the App Store charged the user's account for a purchased or restored
 	// product, or the time the App Store charged the user’s account for a
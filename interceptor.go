@@ -0,0 +1,97 @@
+package storekit
+
+import (
+	"context"
+	"time"
+)
+
+// Interceptor hooks into every request the verification client makes to the
+// App Store, including retries and the environment auto fix. It lets logging,
+// metrics, tracing, secret injection and fault injection be composed around
+// Verify. Any of the funcs may be nil.
+//
+// BeforeSend funcs are called in the order the interceptors were added, and
+// AfterReceive and OnError funcs in the reverse order.
+type Interceptor struct {
+	// BeforeSend is called before a request is sent. req is a copy of the
+	// caller's request made for this attempt and may be modified. The returned
+	// context is used for the request and passed to the following interceptors.
+	//
+	// Returning an error fails the attempt with that error without sending the
+	// request. Only OnError of the interceptors added before this one is then
+	// called.
+	BeforeSend func(ctx context.Context, attempt *Attempt, req *ReceiptRequest) (context.Context, error)
+
+	// AfterReceive is called once a response has been decoded, whatever its
+	// status.
+	AfterReceive func(ctx context.Context, attempt *Attempt, resp *ReceiptResponse)
+
+	// OnError is called when no response could be decoded: the request failed to
+	// send, the App Store answered with a non-200 HTTP status, or the body was
+	// not a receipt response. attempt.Err is set to err.
+	OnError func(ctx context.Context, attempt *Attempt, err error)
+}
+
+// LoggingInterceptor logs every request made to the App Store with logf, e.g.
+// log.Printf. Receipt data and shared secrets are never logged.
+func LoggingInterceptor(logf func(format string, args ...interface{})) Interceptor {
+	return Interceptor{
+		BeforeSend: func(ctx context.Context, attempt *Attempt, req *ReceiptRequest) (context.Context, error) {
+			logf("storekit: attempt %d: verifying receipt with %s", attempt.Number, attempt.URL)
+			return ctx, nil
+		},
+		AfterReceive: func(ctx context.Context, attempt *Attempt, resp *ReceiptResponse) {
+			logf("storekit: attempt %d: %s answered with status %d in %s", attempt.Number, attempt.Environment, resp.Status, attempt.Latency)
+		},
+		OnError: func(ctx context.Context, attempt *Attempt, err error) {
+			logf("storekit: attempt %d: %s failed in %s: %v", attempt.Number, attempt.Environment, attempt.Latency, err)
+		},
+	}
+}
+
+// TimingInterceptor calls observe with the latency of every request made to the
+// App Store, along with the environment it was sent to and its error, if any.
+func TimingInterceptor(observe func(env Environment, latency time.Duration, err error)) Interceptor {
+	return Interceptor{
+		AfterReceive: func(ctx context.Context, attempt *Attempt, resp *ReceiptResponse) {
+			observe(attempt.Environment, attempt.Latency, attempt.Err)
+		},
+		OnError: func(ctx context.Context, attempt *Attempt, err error) {
+			observe(attempt.Environment, attempt.Latency, err)
+		},
+	}
+}
+
+// beforeSend runs the BeforeSend funcs of the client's interceptors. It returns
+// the number of interceptors that let the request through.
+func (c *Client) beforeSend(ctx context.Context, attempt *Attempt, req *ReceiptRequest) (context.Context, int, error) {
+	for i, interceptor := range c.interceptors {
+		if interceptor.BeforeSend == nil {
+			continue
+		}
+
+		var err error
+		ctx, err = interceptor.BeforeSend(ctx, attempt, req)
+		if err != nil {
+			return ctx, i, err
+		}
+	}
+	return ctx, len(c.interceptors), nil
+}
+
+// afterReceive runs the AfterReceive or OnError funcs of the first n of the
+// client's interceptors, depending on whether a response was decoded.
+func (c *Client) afterReceive(ctx context.Context, n int, attempt *Attempt, resp *ReceiptResponse, err error) {
+	for i := n - 1; i >= 0; i-- {
+		interceptor := c.interceptors[i]
+		if resp != nil {
+			if interceptor.AfterReceive != nil {
+				interceptor.AfterReceive(ctx, attempt, resp)
+			}
+		} else {
+			if interceptor.OnError != nil {
+				interceptor.OnError(ctx, attempt, err)
+			}
+		}
+	}
+}
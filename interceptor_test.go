@@ -0,0 +1,127 @@
+package storekit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+type interceptorKey struct{}
+
+// recordingInterceptor returns an interceptor appending its calls to events,
+// and failing BeforeSend with err if set.
+func recordingInterceptor(name string, events *[]string, err error) Interceptor {
+	return Interceptor{
+		BeforeSend: func(ctx context.Context, attempt *Attempt, req *ReceiptRequest) (context.Context, error) {
+			*events = append(*events, fmt.Sprintf("%s.BeforeSend(%v)", name, ctx.Value(interceptorKey{})))
+			return context.WithValue(ctx, interceptorKey{}, name), err
+		},
+		AfterReceive: func(ctx context.Context, attempt *Attempt, resp *ReceiptResponse) {
+			*events = append(*events, name+".AfterReceive")
+		},
+		OnError: func(ctx context.Context, attempt *Attempt, err error) {
+			if attempt.Err != err {
+				*events = append(*events, name+".OnError without attempt error")
+			}
+			*events = append(*events, name+".OnError")
+		},
+	}
+}
+
+func TestInterceptorOrder(t *testing.T) {
+	ok := newAppStore(t, ReceiptResponseStatusOK)
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	tests := []struct {
+		url  string
+		want []string
+	}{
+		{ok.URL, []string{"a.BeforeSend(<nil>)", "b.BeforeSend(a)", "c.BeforeSend(b)", "c.AfterReceive", "b.AfterReceive", "a.AfterReceive"}},
+		{down.URL, []string{"a.BeforeSend(<nil>)", "b.BeforeSend(a)", "c.BeforeSend(b)", "c.OnError", "b.OnError", "a.OnError"}},
+	}
+	for _, tt := range tests {
+		var events []string
+		c, err := NewClient(
+			WithProductionURL(tt.url),
+			WithRetryPolicy(NoRetry),
+			WithInterceptors(recordingInterceptor("a", &events, nil), Interceptor{}),
+			WithInterceptors(recordingInterceptor("b", &events, nil), recordingInterceptor("c", &events, nil)),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, _ = c.Verify(context.Background(), &ReceiptRequest{ReceiptData: []byte("receipt")})
+		if !reflect.DeepEqual(events, tt.want) {
+			t.Errorf("calls = %q; want %q", events, tt.want)
+		}
+	}
+}
+
+func TestInterceptorBeforeSendError(t *testing.T) {
+	store := newAppStore(t, ReceiptResponseStatusOK)
+	injected := errors.New("injected fault")
+
+	var events []string
+	c, err := NewClient(
+		WithProductionURL(store.URL),
+		WithRetryPolicy(NoRetry),
+		WithInterceptors(
+			recordingInterceptor("a", &events, nil),
+			recordingInterceptor("b", &events, injected),
+			recordingInterceptor("c", &events, nil),
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := c.Verify(context.Background(), &ReceiptRequest{ReceiptData: []byte("receipt")})
+	if !errors.Is(err, injected) || len(result.Attempts) != 1 || !errors.Is(result.Attempts[0].Err, injected) {
+		t.Errorf("Verify() error = %v, attempts = %+v; want the injected fault", err, result.Attempts)
+	}
+	if want := []string{"a.BeforeSend(<nil>)", "b.BeforeSend(a)", "a.OnError"}; !reflect.DeepEqual(events, want) {
+		t.Errorf("calls = %q; want %q", events, want)
+	}
+	if got := store.requests(); len(got) != 0 {
+		t.Errorf("%d requests sent; want none", len(got))
+	}
+}
+
+func TestInterceptorRequestCopy(t *testing.T) {
+	store := newAppStore(t, ReceiptResponseStatusOK)
+
+	c, err := NewClient(
+		WithProductionURL(store.URL),
+		WithInterceptors(Interceptor{
+			BeforeSend: func(ctx context.Context, attempt *Attempt, req *ReceiptRequest) (context.Context, error) {
+				req.Password = "injected"
+				req.ReceiptData = []byte("replaced")
+				req.ExcludeOldTransactions = true
+				return ctx, nil
+			},
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := &ReceiptRequest{ReceiptData: []byte("receipt")}
+	if _, err := c.Verify(context.Background(), req); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if got := store.requests(); len(got) != 1 || got[0] != "injected" {
+		t.Errorf("passwords sent = %q; want [injected]", got)
+	}
+	if want := (&ReceiptRequest{ReceiptData: []byte("receipt")}); !reflect.DeepEqual(req, want) {
+		t.Errorf("caller's request = %+v; want it unchanged", req)
+	}
+}
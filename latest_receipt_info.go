@@ -1,5 +1,9 @@
 package storekit
 
+import (
+	"encoding/json"
+)
+
 // InAppOwnershipType is the relationship of the user with the family-shared
 // purchase to which they have access.
 //
@@ -31,7 +35,7 @@ type LatestReceiptInfo struct {
 	// milliseconds. This field is only present for refunded transactions. Use this
 	// time format for processing dates.
 	// information.
-	CancellationDateMs int64 `json:"cancellation_date_ms,string,omitempty"`
+	CancellationDateMs Timestamp `json:"cancellation_date_ms,omitempty"`
 
 	// The time Apple customer support canceled a transaction, in the Pacific Time
 	// zone. This field is only present for refunded transactions.
@@ -51,7 +55,7 @@ type LatestReceiptInfo struct {
 
 	// The time a subscription expires or when it will renew, in UNIX epoch time
 	// format, in milliseconds. Use this time format for processing dates.
-	ExpiresDateMs int64 `json:"expires_date_ms,string,omitempty"`
+	ExpiresDateMs Timestamp `json:"expires_date_ms,omitempty"`
 
 	// The time a subscription expires or when it will renew, in the Pacific Time
 	// zone.
@@ -67,14 +71,14 @@ type LatestReceiptInfo struct {
 
 	// An indicator of whether an auto-renewable subscription is in the introductory
 	// price period.
-	IsInIntroOfferPeriod string `json:"is_in_intro_offer_period,omitempty"`
+	IsInIntroOfferPeriod *Flag `json:"is_in_intro_offer_period,omitempty"`
 
 	// An indicator of whether a subscription is in the free trial period.
-	IsTrialPeriod string `json:"is_trial_period,omitempty"`
+	IsTrialPeriod *Flag `json:"is_trial_period,omitempty"`
 
 	// An indicator that a subscription has been canceled due to an upgrade. This
 	// field is only present for upgrade transactions.
-	IsUpgraded string `json:"is_upgraded,omitempty"`
+	IsUpgraded *Flag `json:"is_upgraded,omitempty"`
 
 	// The reference name of a subscription offer that you configured in App Store
 	// Connect. This field is present when a customer redeemed a subscription offer
@@ -93,7 +97,7 @@ type LatestReceiptInfo struct {
 	// product types and remains the same in all transactions for the same product
 	// ID. This value corresponds to the original transaction’s transactionDate
 	// property in StoreKit.
-	OriginalPurchaseDateMs int64 `json:"original_purchase_date_ms,string,omitempty"`
+	OriginalPurchaseDateMs Timestamp `json:"original_purchase_date_ms,omitempty"`
 
 	// The time of the original app purchase, in the Pacific Time zone.
 	OriginalPurchaseDatePst string `json:"original_purchase_date_pst,omitempty"`
@@ -121,7 +125,7 @@ type LatestReceiptInfo struct {
 	// time the App Store charged the user’s account for a subscription purchase or
 	// renewal after a lapse, in the UNIX epoch time format, in milliseconds. Use this
 	// time format for processing dates.
-	PurchaseDateMs int64 `json:"purchase_date_ms,string,omitempty"`
+	PurchaseDateMs Timestamp `json:"purchase_date_ms,omitempty"`
 
 	// The time the App Store charged the user's account for a purchased or restored
 	// product, or the time the App Store charged the user’s account for a subscription
@@ -146,4 +150,8 @@ type LatestReceiptInfo struct {
 	// subscription-renewal events. This value is the primary key for identifying
 	// subscription purchases.
 	WebOrderLineItemId string `json:"web_order_line_item_id,omitempty"`
+
+	// Extra holds the keys of the JSON data that match none of the fields above,
+	// as sent by the App Store. They are written back when marshaling.
+	Extra map[string]json.RawMessage `json:"-"`
 }
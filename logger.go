@@ -0,0 +1,216 @@
+package storekit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+)
+
+// LogLevel is the severity of a log entry.
+type LogLevel int
+
+const (
+	LogLevelDebug LogLevel = iota
+	LogLevelInfo
+	LogLevelWarn
+	LogLevelError
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogLevelDebug:
+		return "debug"
+	case LogLevelInfo:
+		return "info"
+	case LogLevelWarn:
+		return "warn"
+	case LogLevelError:
+		return "error"
+	default:
+		return "level(" + strconv.Itoa(int(l)) + ")"
+	}
+}
+
+// Field is a key-value pair attached to a log entry.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Logger is a structured logger. Adapt zap, zerolog, logrus or log/slog to it,
+// or use LoggerFunc.
+//
+// The client never hands receipt data or shared secrets to the logger.
+type Logger interface {
+	Log(ctx context.Context, level LogLevel, msg string, fields ...Field)
+}
+
+// LoggerFunc adapts an ordinary function to the Logger interface.
+type LoggerFunc func(ctx context.Context, level LogLevel, msg string, fields ...Field)
+
+func (f LoggerFunc) Log(ctx context.Context, level LogLevel, msg string, fields ...Field) {
+	f(ctx, level, msg, fields...)
+}
+
+type noopLogger struct{}
+
+func (noopLogger) Log(ctx context.Context, level LogLevel, msg string, fields ...Field) {}
+
+// logAttempt logs a request made to the App Store.
+func (c *Client) logAttempt(ctx context.Context, attempt *Attempt, req *ReceiptRequest) {
+	fields := []Field{
+		{"attempt", attempt.Number},
+		{"url", attempt.URL},
+		{"environment", string(attempt.Environment)},
+		{"latency", attempt.Latency},
+		{"receipt_data", redactBytes(req.ReceiptData)},
+		{"password", redactString(req.Password)},
+		{"exclude_old_transactions", req.ExcludeOldTransactions},
+	}
+	if attempt.HTTPStatusCode != 0 {
+		fields = append(fields, Field{"http_status", attempt.HTTPStatusCode})
+	}
+
+	level := LogLevelDebug
+	switch class := ErrorClass(attempt.Err); class {
+	case ErrorClassNone:
+		fields = append(fields, Field{"status", int(attempt.Status)})
+	case ErrorClassStatus:
+		fields = append(fields, Field{"status", int(attempt.Status)}, Field{"error_class", class})
+	default:
+		level = LogLevelWarn
+		fields = append(fields, Field{"error", attempt.Err.Error()}, Field{"error_class", class})
+	}
+
+	c.logger.Log(ctx, level, "storekit: app store attempt", fields...)
+}
+
+// logVerify logs the outcome of a Verify call.
+func (c *Client) logVerify(ctx context.Context, result *VerifyResult, err error) {
+	fields := []Field{
+		{"environment", string(result.Environment)},
+		{"environment_auto_fixed", result.EnvironmentAutoFixed},
+		{"attempts", len(result.Attempts)},
+	}
+	if result.Response != nil {
+		fields = append(fields,
+			Field{"status", int(result.Response.Status)},
+			Field{"latest_receipt", truncateBytes(result.Response.LatestReceipt)},
+		)
+		if result.Response.Receipt.BundleId != "" {
+			fields = append(fields, Field{"bundle_id", result.Response.Receipt.BundleId})
+		}
+	}
+
+	level := LogLevelInfo
+	if err != nil {
+		level = LogLevelError
+		if IsRetryable(err) {
+			level = LogLevelWarn
+		}
+		fields = append(fields, Field{"error", err.Error()}, Field{"error_class", ErrorClass(err)})
+	}
+
+	c.logger.Log(ctx, level, "storekit: receipt verification", fields...)
+}
+
+const (
+	redacted = "[REDACTED]"
+
+	// truncatedLength is how many leading bytes of a receipt are kept by
+	// truncation. Receipts share most of their header, so a digest of the whole
+	// receipt follows them to tell receipts apart in logs.
+	truncatedLength = 16
+
+	// digestLength is how many hex digits of the SHA-256 digest are kept.
+	digestLength = 16
+)
+
+// Redacted returns a copy of the request that is safe to log: the receipt data
+// and password are replaced with a placeholder.
+func (r ReceiptRequest) Redacted() ReceiptRequest {
+	if len(r.ReceiptData) > 0 {
+		r.ReceiptData = []byte(redacted)
+	}
+	r.Password = redactString(r.Password)
+	return r
+}
+
+// String implements fmt.Stringer without revealing the receipt data or
+// password, so that printing a request with %v or %s is safe.
+func (r ReceiptRequest) String() string {
+	return fmt.Sprintf(
+		"{ReceiptData:%s Password:%s ExcludeOldTransactions:%t}",
+		redactBytes(r.ReceiptData),
+		redactString(r.Password),
+		r.ExcludeOldTransactions,
+	)
+}
+
+// GoString implements fmt.GoStringer like String, for %#v.
+func (r ReceiptRequest) GoString() string {
+	return "storekit.ReceiptRequest" + r.String()
+}
+
+// Redacted returns a copy of the response that is safe to log: the latest
+// receipt is truncated.
+func (r ReceiptResponse) Redacted() ReceiptResponse {
+	r.LatestReceipt = truncate(r.LatestReceipt)
+	return r
+}
+
+// Redacted returns a copy of the notification that is safe to log: the
+// password is replaced with a placeholder and the latest receipt is truncated.
+func (n Notification) Redacted() Notification {
+	n.Password = redactString(n.Password)
+	n.UnifiedReceipt.LatestReceipt = truncate(n.UnifiedReceipt.LatestReceipt)
+	return n
+}
+
+// String implements fmt.Stringer without revealing the password or the full
+// latest receipt, so that printing a notification with %v or %s is safe.
+func (n Notification) String() string {
+	type notification Notification // Drops the String method.
+	return fmt.Sprintf("%+v", notification(n.Redacted()))
+}
+
+// GoString implements fmt.GoStringer like String, for %#v.
+func (n Notification) GoString() string {
+	return "storekit.Notification" + n.String()
+}
+
+func redactString(s string) string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+func redactBytes(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	return redacted + " (" + strconv.Itoa(len(b)) + " bytes)"
+}
+
+func truncate(b []byte) []byte {
+	if len(b) <= truncatedLength {
+		return b
+	}
+	return append(b[:truncatedLength:truncatedLength], "... sha256:"+digest(b)...)
+}
+
+func truncateBytes(b []byte) string {
+	if len(b) <= truncatedLength {
+		return string(b)
+	}
+	return string(b[:truncatedLength]) + "... (" + strconv.Itoa(len(b)) + " bytes, sha256:" + digest(b) + ")"
+}
+
+// digest returns a short SHA-256 digest of b in hex.
+func digest(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:digestLength/2])
+}
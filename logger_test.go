@@ -0,0 +1,27 @@
+package storekit
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTruncateTellsReceiptsApart(t *testing.T) {
+	// Base64 PKCS#7 receipts start alike.
+	a := []byte("MIIT0gYJKoZIhvcNAQcCoIITwzCCE78CAQExCzAJBgUrDgMCGgUAMIIDcwYJKoZIhvcNAQcBoIIDZASCA2Ax")
+	b := []byte("MIIT0gYJKoZIhvcNAQcCoIITwzCCE78CAQExCzAJBgUrDgMCGgUAMIIDcwYJKoZIhvcNAQcBoIIDZASCA2Ay")
+
+	if ta, tb := truncateBytes(a), truncateBytes(b); ta == tb {
+		t.Errorf("truncateBytes() = %q for both receipts", ta)
+	}
+	if ta, tb := truncate(a), truncate(b); bytes.Equal(ta, tb) {
+		t.Errorf("truncate() = %q for both receipts", ta)
+	}
+	if got, want := truncateBytes(a), "MIIT0gYJKoZIhvcN... (84 bytes, sha256:"+digest(a)+")"; got != want {
+		t.Errorf("truncateBytes() = %q; want %q", got, want)
+	}
+
+	short := []byte("MIIT0gYJ")
+	if got := truncateBytes(short); got != string(short) {
+		t.Errorf("truncateBytes(%q) = %q; want it unchanged", short, got)
+	}
+}
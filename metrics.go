@@ -0,0 +1,324 @@
+package storekit
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Metrics receives measurements of the client's verifications.
+// PrometheusCollector is an implementation ready to be scraped by Prometheus.
+type Metrics interface {
+	// ObserveVerify is called once a Verify call returns.
+	ObserveVerify(result *VerifyResult, duration time.Duration, err error)
+
+	// ObserveAttempt is called after every request made to the App Store.
+	ObserveAttempt(attempt *Attempt)
+}
+
+// Error classes returned by ErrorClass.
+const (
+	ErrorClassNone      = "none"
+	ErrorClassTransport = "transport"
+	ErrorClassHTTP      = "http"
+	ErrorClassDecode    = "decode"
+	ErrorClassStatus    = "status"
+	ErrorClassCircuit   = "circuit_open"
+	ErrorClassCanceled  = "canceled"
+	ErrorClassOther     = "other"
+)
+
+// ErrorClass returns a short, low cardinality name for the kind of err, suitable
+// as a metric label.
+func ErrorClass(err error) string {
+	var (
+		transportErr *TransportError
+		httpErr      *HTTPStatusError
+		decodeErr    *DecodeError
+		statusErr    *StatusError
+	)
+
+	switch {
+	case err == nil:
+		return ErrorClassNone
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return ErrorClassCanceled
+	case errors.Is(err, ErrCircuitOpen):
+		return ErrorClassCircuit
+	case errors.As(err, &transportErr):
+		return ErrorClassTransport
+	case errors.As(err, &httpErr):
+		return ErrorClassHTTP
+	case errors.As(err, &decodeErr):
+		return ErrorClassDecode
+	case errors.As(err, &statusErr):
+		return ErrorClassStatus
+	default:
+		return ErrorClassOther
+	}
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) ObserveVerify(result *VerifyResult, duration time.Duration, err error) {}
+func (noopMetrics) ObserveAttempt(attempt *Attempt)                                       {}
+
+// DefaultLatencyBuckets are the histogram buckets, in seconds, used by
+// NewPrometheusCollector.
+var DefaultLatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// PrometheusCollector is a Metrics implementation that aggregates counters and
+// latency histograms and exposes them in the Prometheus text exposition format.
+// Mount it as an http.Handler, or write it out with WriteTo from an existing
+// metrics endpoint.
+//
+// The following metrics are collected:
+//
+//	storekit_verify_total{environment,status,error_class,retries}
+//	storekit_verify_duration_seconds{environment,error_class}
+//	storekit_attempts_total{environment,status,error_class}
+//	storekit_attempt_duration_seconds{environment}
+//
+// The retries label counts the attempts made by the retry policy, not those of
+// the environment auto fix or of shared secret rotation. The status label is
+// the App Store status code, or "none" if no response was decoded. Alerting on
+// spikes of status="21003" or status="21004" catches forged receipts and shared
+// secret mismatches.
+type PrometheusCollector struct {
+	mu sync.Mutex
+
+	verifyTotal     *counterVec
+	verifyDuration  *histogramVec
+	attemptsTotal   *counterVec
+	attemptDuration *histogramVec
+}
+
+// NewPrometheusCollector returns a collector using buckets for latency
+// histograms, or DefaultLatencyBuckets if none are given.
+func NewPrometheusCollector(buckets ...float64) *PrometheusCollector {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	return &PrometheusCollector{
+		verifyTotal: newCounterVec(
+			"storekit_verify_total",
+			"Receipt verifications by final App Store status.",
+			"environment", "status", "error_class", "retries",
+		),
+		verifyDuration: newHistogramVec(
+			"storekit_verify_duration_seconds",
+			"Time taken by receipt verifications, including retries.",
+			buckets,
+			"environment", "error_class",
+		),
+		attemptsTotal: newCounterVec(
+			"storekit_attempts_total",
+			"Requests made to the App Store server.",
+			"environment", "status", "error_class",
+		),
+		attemptDuration: newHistogramVec(
+			"storekit_attempt_duration_seconds",
+			"App Store server latency.",
+			buckets,
+			"environment",
+		),
+	}
+}
+
+func (p *PrometheusCollector) ObserveVerify(result *VerifyResult, duration time.Duration, err error) {
+	status := "none"
+	if result.Response != nil {
+		status = strconv.Itoa(int(result.Response.Status))
+	}
+	retries := 0
+	for _, attempt := range result.Attempts {
+		if attempt.Retry {
+			retries++
+		}
+	}
+	env := string(result.Environment)
+	class := ErrorClass(err)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.verifyTotal.inc(env, status, class, strconv.Itoa(retries))
+	p.verifyDuration.observe(duration.Seconds(), env, class)
+}
+
+func (p *PrometheusCollector) ObserveAttempt(attempt *Attempt) {
+	status := "none"
+	if attempt.Err == nil || ErrorClass(attempt.Err) == ErrorClassStatus {
+		status = strconv.Itoa(int(attempt.Status))
+	}
+	env := string(attempt.Environment)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.attemptsTotal.inc(env, status, ErrorClass(attempt.Err))
+	p.attemptDuration.observe(attempt.Latency.Seconds(), env)
+}
+
+// WriteTo writes all collected metrics to w in the Prometheus text exposition
+// format.
+func (p *PrometheusCollector) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+
+	p.mu.Lock()
+	p.verifyTotal.writeTo(cw)
+	p.verifyDuration.writeTo(cw)
+	p.attemptsTotal.writeTo(cw)
+	p.attemptDuration.writeTo(cw)
+	p.mu.Unlock()
+
+	if cw.err != nil {
+		return cw.n, cw.err
+	}
+	return cw.n, cw.w.Flush()
+}
+
+// ServeHTTP serves the collected metrics to a Prometheus scraper.
+func (p *PrometheusCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = p.WriteTo(w)
+}
+
+type counterVec struct {
+	name, help string
+	labels     []string
+	values     map[string]float64
+}
+
+func newCounterVec(name, help string, labels ...string) *counterVec {
+	return &counterVec{name: name, help: help, labels: labels, values: map[string]float64{}}
+}
+
+func (v *counterVec) inc(labelValues ...string) {
+	v.values[formatLabels(v.labels, labelValues)]++
+}
+
+func (v *counterVec) writeTo(w *countingWriter) {
+	w.printf("# HELP %s %s\n# TYPE %s counter\n", v.name, v.help, v.name)
+	for _, labels := range sortedKeys(v.values) {
+		w.printf("%s{%s} %s\n", v.name, labels, formatFloat(v.values[labels]))
+	}
+}
+
+type histogramVec struct {
+	name, help string
+	buckets    []float64
+	labels     []string
+	values     map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64 // Per bucket, not cumulative.
+	count  uint64
+	sum    float64
+}
+
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *histogramVec {
+	return &histogramVec{name: name, help: help, buckets: buckets, labels: labels, values: map[string]*histogram{}}
+}
+
+func (v *histogramVec) observe(value float64, labelValues ...string) {
+	key := formatLabels(v.labels, labelValues)
+	h, ok := v.values[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(v.buckets))}
+		v.values[key] = h
+	}
+
+	for i, upperBound := range v.buckets {
+		if value <= upperBound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += value
+}
+
+func (v *histogramVec) writeTo(w *countingWriter) {
+	w.printf("# HELP %s %s\n# TYPE %s histogram\n", v.name, v.help, v.name)
+
+	keys := make([]string, 0, len(v.values))
+	for key := range v.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, labels := range keys {
+		h := v.values[labels]
+		sep := ""
+		if labels != "" {
+			sep = ","
+		}
+
+		var cumulative uint64
+		for i, upperBound := range v.buckets {
+			cumulative += h.counts[i]
+			w.printf("%s_bucket{%s%sle=\"%s\"} %d\n", v.name, labels, sep, formatFloat(upperBound), cumulative)
+		}
+		w.printf("%s_bucket{%s%sle=\"+Inf\"} %d\n", v.name, labels, sep, h.count)
+		w.printf("%s_sum{%s} %s\n", v.name, labels, formatFloat(h.sum))
+		w.printf("%s_count{%s} %d\n", v.name, labels, h.count)
+	}
+}
+
+func formatLabels(names, values []string) string {
+	var b strings.Builder
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(labelValueEscaper.Replace(values[i]))
+		b.WriteByte('"')
+	}
+	return b.String()
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (w *countingWriter) printf(format string, args ...interface{}) {
+	if w.err != nil {
+		return
+	}
+	var n int
+	n, w.err = fmt.Fprintf(w.w, format, args...)
+	w.n += int64(n)
+}
@@ -0,0 +1,52 @@
+package storekit
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheusCollectorCountsRetriesOnly(t *testing.T) {
+	production := newAppStore(t,
+		ReceiptResponseStatusReceiptServerUnavailable,
+		ReceiptResponseStatusSandboxReceiptSentToProduction,
+	)
+	sandbox := newAppStore(t, ReceiptResponseStatusOK)
+	sandbox.Environment = EnvironmentSandbox
+
+	metrics := NewPrometheusCollector()
+	c, err := NewClient(
+		WithProductionURL(production.URL),
+		WithSandboxURL(sandbox.URL),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}),
+		WithMetrics(metrics),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A retry in production, then the auto fix to the sandbox.
+	result, err := c.Verify(context.Background(), &ReceiptRequest{ReceiptData: []byte("receipt")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(result.Attempts); got != 3 {
+		t.Fatalf("Verify() attempts = %d; want 3", got)
+	}
+	for i, want := range []bool{false, true, false} {
+		if result.Attempts[i].Retry != want {
+			t.Errorf("attempt %d: Retry = %t; want %t", i+1, result.Attempts[i].Retry, want)
+		}
+	}
+
+	var buf bytes.Buffer
+	if _, err := metrics.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `storekit_verify_total{environment="Sandbox",status="0",error_class="none",retries="1"} 1`
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("metrics don't contain %s:\n%s", want, buf.String())
+	}
+}
@@ -119,7 +119,7 @@ type Notification struct {
 	// that these values are different from those of the auto_renew_status in the
 	// receipt.
 	// Possible values: true, false
-	AutoRenewStatus string `json:"auto_renew_status,omitempty"`
+	AutoRenewStatus *Flag `json:"auto_renew_status,omitempty"`
 
 	// The time at which the user turned on or off the renewal status for an
 	// auto-renewable subscription, in a date-time format similar to the ISO 8601
@@ -129,7 +129,7 @@ type Notification struct {
 	// The time at which the user turned on or off the renewal status for an
 	// auto-renewable subscription, in UNIX epoch time format, in milliseconds. Use
 	// this time format to process dates.
-	AutoRenewStatusChangeDateMs int64 `json:"auto_renew_status_change_date_ms,string,omitempty"`
+	AutoRenewStatusChangeDateMs Timestamp `json:"auto_renew_status_change_date_ms,omitempty"`
 
 	// The time at which the user turned on or off the renewal status for an
 	// auto-renewable subscription, in the Pacific time zone.
@@ -0,0 +1,248 @@
+package storekit
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/pkg/errors"
+)
+
+// Option configures a Client created with NewClient. Options report invalid
+// values as errors from NewClient.
+type Option func(c *Client) error
+
+// WithEnvironment sets the environment receipts are verified in first.
+// Defaults to EnvironmentProduction.
+func WithEnvironment(env Environment) Option {
+	return func(c *Client) error {
+		if env != EnvironmentSandbox && env != EnvironmentProduction {
+			return errors.Errorf("invalid environment %q", env)
+		}
+		c.environment = env
+		return nil
+	}
+}
+
+// WithoutEnvAutoFix disables automatic handling of incompatible receipt
+// environment error.
+func WithoutEnvAutoFix() Option {
+	return func(c *Client) error {
+		c.autofixEnvironment = false
+		return nil
+	}
+}
+
+// WithSandboxURL sends sandbox verifications to rawURL instead of
+// SandboxReceiptVerificationURL.
+func WithSandboxURL(rawURL string) Option {
+	return func(c *Client) error {
+		if err := validateURL(rawURL); err != nil {
+			return errors.Wrap(err, "invalid sandbox url")
+		}
+		c.sandboxURL = rawURL
+		return nil
+	}
+}
+
+// WithProductionURL sends production verifications to rawURL instead of
+// ProductionReceiptVerificationURL.
+func WithProductionURL(rawURL string) Option {
+	return func(c *Client) error {
+		if err := validateURL(rawURL); err != nil {
+			return errors.Wrap(err, "invalid production url")
+		}
+		c.productionURL = rawURL
+		return nil
+	}
+}
+
+// WithHTTPClient uses doer to reach the App Store server. A nil doer restores
+// the default client.
+func WithHTTPClient(doer HTTPDoer) Option {
+	return func(c *Client) error {
+		if doer == nil {
+			doer = NewDefaultHTTPClient()
+		}
+		c.httpClient = doer
+		return nil
+	}
+}
+
+// WithRetryPolicy retries transient failures according to policy. Use NoRetry
+// to disable retries.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) error {
+		switch {
+		case policy.MaxAttempts < 0:
+			return errors.New("invalid retry policy: negative max attempts")
+		case policy.InitialBackoff < 0, policy.MaxBackoff < 0:
+			return errors.New("invalid retry policy: negative backoff")
+		case policy.Multiplier < 0:
+			return errors.New("invalid retry policy: negative multiplier")
+		case policy.Jitter < 0 || policy.Jitter > 1:
+			return errors.New("invalid retry policy: jitter out of [0, 1]")
+		}
+		c.retryPolicy = policy
+		return nil
+	}
+}
+
+// WithInterceptors runs the given interceptors around every request made to
+// the App Store, after those already added.
+func WithInterceptors(interceptors ...Interceptor) Option {
+	return func(c *Client) error {
+		c.interceptors = append(append([]Interceptor(nil), c.interceptors...), interceptors...)
+		return nil
+	}
+}
+
+// WithTracer traces verifications with tracer. A nil tracer disables tracing.
+func WithTracer(tracer Tracer) Option {
+	return func(c *Client) error {
+		if tracer == nil {
+			tracer = noopTracer{}
+		}
+		c.tracer = tracer
+		return nil
+	}
+}
+
+// WithMetrics reports measurements of verifications to metrics. A nil metrics
+// disables reporting.
+func WithMetrics(metrics Metrics) Option {
+	return func(c *Client) error {
+		if metrics == nil {
+			metrics = noopMetrics{}
+		}
+		c.metrics = metrics
+		return nil
+	}
+}
+
+// WithLogger logs verification attempts to logger. Receipt data and shared
+// secrets are redacted. A nil logger disables logging.
+func WithLogger(logger Logger) Option {
+	return func(c *Client) error {
+		if logger == nil {
+			logger = noopLogger{}
+		}
+		c.logger = logger
+		return nil
+	}
+}
+
+// WithMaxResponseSize refuses App Store responses larger than size bytes,
+// failing with ErrResponseTooLarge. Defaults to DefaultMaxResponseSize.
+func WithMaxResponseSize(size int64) Option {
+	return func(c *Client) error {
+		if size <= 0 {
+			return errors.New("invalid max response size: must be positive")
+		}
+		c.maxResponseSize = size
+		return nil
+	}
+}
+
+// WithRateLimit sends at most ratePerSecond requests per second to the App
+// Store, allowing bursts of up to burst requests. Waiting for the limit
+// respects the context deadline. A rate of zero removes the limit.
+//
+// The limit is shared with the clients derived from the configured one.
+func WithRateLimit(ratePerSecond float64, burst int) Option {
+	return func(c *Client) error {
+		if ratePerSecond < 0 || burst < 0 {
+			return errors.New("invalid rate limit: negative rate or burst")
+		}
+		c.rateLimiter = nil
+		if ratePerSecond > 0 {
+			c.rateLimiter = newTokenBucket(ratePerSecond, burst)
+		}
+		return nil
+	}
+}
+
+// WithMaxInFlight has at most n requests to the App Store in flight at once.
+// Zero removes the limit.
+//
+// The limit is shared with the clients derived from the configured one.
+func WithMaxInFlight(n int) Option {
+	return func(c *Client) error {
+		if n < 0 {
+			return errors.New("invalid max in flight: negative")
+		}
+		c.inFlight = nil
+		if n > 0 {
+			c.inFlight = make(chan struct{}, n)
+		}
+		return nil
+	}
+}
+
+// WithCircuitBreaker stops sending requests to an App Store endpoint while it
+// appears degraded, failing fast with a *CircuitOpenError instead.
+//
+// The breakers are shared with the clients derived from the configured one.
+func WithCircuitBreaker(settings CircuitBreakerSettings) Option {
+	return func(c *Client) error {
+		if settings.FailureThreshold < 0 || settings.OpenTimeout < 0 || settings.HalfOpenRequests < 0 {
+			return errors.New("invalid circuit breaker settings: negative value")
+		}
+		c.circuitBreakers = newCircuitBreakers(settings)
+		return nil
+	}
+}
+
+// WithSharedSecrets verifies requests without a password with the given app
+// shared secrets. The secrets are tried in order, moving to the next one on a
+// 21004 status, so that a secret can be rotated in App Store Connect without
+// an outage: list the new secret first and the old one after it until the
+// rotation is complete.
+//
+// VerifyResult.SharedSecretIndex reports the index of the secret used for the
+// final response.
+func WithSharedSecrets(secrets ...string) Option {
+	return func(c *Client) error {
+		for _, secret := range secrets {
+			if secret == "" {
+				return errors.New("invalid shared secrets: empty secret")
+			}
+		}
+		c.sharedSecrets = append([]string(nil), secrets...)
+		return nil
+	}
+}
+
+// WithSandboxPolicy lets policy decide whether sandbox receipts are acceptable
+// when verifying in production. A rejected receipt fails with
+// ErrSandboxReceiptRejected. A nil policy accepts all sandbox receipts.
+func WithSandboxPolicy(policy SandboxPolicy) Option {
+	return func(c *Client) error {
+		c.sandboxPolicy = policy
+		return nil
+	}
+}
+
+// WithStrictDecoding compares every App Store response with the
+// ReceiptResponse model in shadow of regular decoding, and calls report with
+// the warnings found, if any, as DecodeStrict would. Responses are decoded as
+// usual regardless of the warnings.
+func WithStrictDecoding(report func(ctx context.Context, warnings []DecodeWarning)) Option {
+	return func(c *Client) error {
+		c.strictDecoding = report
+		return nil
+	}
+}
+
+func validateURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.Errorf("%q is not an http or https url", rawURL)
+	}
+	if u.Host == "" {
+		return errors.Errorf("%q has no host", rawURL)
+	}
+	return nil
+}
@@ -1,5 +1,9 @@
 package storekit
 
+import (
+	"encoding/json"
+)
+
 // AutoRenewStatus is returned in the JSON response, in the
 // responseBody.Pending_renewal_info array.
 //
@@ -116,7 +120,7 @@ type PendingRenewalInfo struct {
 	// epoch time format, in milliseconds. This key is only present for apps that
 	// have Billing Grace Period enabled and when the user experiences a billing
 	// error at the time of renewal. Use this time format for processing dates.
-	GracePeriodExpiresDateMs int64 `json:"grace_period_expires_date_ms,string,omitempty"`
+	GracePeriodExpiresDateMs Timestamp `json:"grace_period_expires_date_ms,omitempty"`
 
 	// The time at which the grace period for subscription renewals expires, in the
 	// Pacific Time zone.
@@ -146,4 +150,8 @@ type PendingRenewalInfo struct {
 	// productIdentifier property of the SKPayment object stored in the
 	// transaction's payment property.
 	ProductId string `json:"product_id,omitempty"`
+
+	// Extra holds the keys of the JSON data that match none of the fields above,
+	// as sent by the App Store. They are written back when marshaling.
+	Extra map[string]json.RawMessage `json:"-"`
 }
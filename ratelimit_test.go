@@ -0,0 +1,126 @@
+package storekit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestRateLimitRefundsOnDeadline(t *testing.T) {
+	const interval = 200 * time.Millisecond
+
+	store := newAppStore(t, ReceiptResponseStatusOK)
+	c, err := NewClient(WithProductionURL(store.URL), WithRateLimit(float64(time.Second/interval), 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &ReceiptRequest{ReceiptData: []byte("receipt")}
+
+	start := time.Now()
+	if _, err := c.Verify(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+
+	// The next token is an interval away, past the deadline: fail right away.
+	ctx, cancel := context.WithTimeout(context.Background(), interval/4)
+	defer cancel()
+	if _, err := c.Verify(ctx, req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Verify() error = %v; want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed >= interval/4 {
+		t.Errorf("Verify() failed after %s; want right away", elapsed)
+	}
+
+	// Waiting and giving up gives the token back too.
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(interval/4, cancel)
+	if _, err := c.Verify(ctx, req); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Verify() error = %v; want context.Canceled", err)
+	}
+
+	// Without the refunds, the token would be three intervals away.
+	if _, err := c.Verify(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < interval*3/4 || elapsed > interval*7/4 {
+		t.Errorf("second request sent after %s; want about %s", elapsed, interval)
+	}
+	if got := len(store.requests()); got != 2 {
+		t.Errorf("App Store got %d requests; want 2", got)
+	}
+}
+
+func TestMaxInFlight(t *testing.T) {
+	store := newAppStore(t, ReceiptResponseStatusOK)
+	store.Hold = make(chan struct{})
+	c, err := NewClient(WithProductionURL(store.URL), WithMaxInFlight(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &ReceiptRequest{ReceiptData: []byte("receipt")}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.Verify(context.Background(), req)
+		done <- err
+	}()
+	<-store.Received
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.Verify(ctx, req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Verify() with a request in flight error = %v; want context.DeadlineExceeded", err)
+	}
+
+	close(store.Hold)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Verify(context.Background(), req); err != nil {
+		t.Errorf("Verify() once the slot is free error = %v", err)
+	}
+}
+
+func TestMaxInFlightRefundsRateLimit(t *testing.T) {
+	store := newAppStore(t, ReceiptResponseStatusOK)
+	store.Hold = make(chan struct{})
+
+	// Two tokens, and no more for an hour.
+	c, err := NewClient(
+		WithProductionURL(store.URL),
+		WithRateLimit(1.0/3600, 2),
+		WithMaxInFlight(1),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &ReceiptRequest{ReceiptData: []byte("receipt")}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.Verify(context.Background(), req)
+		done <- err
+	}()
+	<-store.Received
+
+	// Takes the second token, then gives up waiting for the slot.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.Verify(ctx, req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Verify() with a request in flight error = %v; want context.DeadlineExceeded", err)
+	}
+
+	close(store.Hold)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// Without the refund, the next token would be an hour away.
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := c.Verify(ctx, req); err != nil {
+		t.Errorf("Verify() once the slot is free error = %v", err)
+	}
+}
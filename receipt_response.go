@@ -1,5 +1,9 @@
 package storekit
 
+import (
+	"encoding/json"
+)
+
 // ReceiptResponseStatus is the status of the app receipt. The value for status
 // is 0 if the receipt is valid, or a status code if there is an error. The
 // status code reflects the status of the app receipt as a whole. For example,
@@ -94,4 +98,8 @@ type ReceiptResponse struct {
 	// Either 0 if the receipt is valid, or a status code if there is an error. The
 	// status code reflects the status of the app receipt as a whole.
 	Status ReceiptResponseStatus `json:"status,omitempty"`
+
+	// Extra holds the keys of the JSON data that match none of the fields above,
+	// as sent by the App Store. They are written back when marshaling.
+	Extra map[string]json.RawMessage `json:"-"`
 }
@@ -0,0 +1,97 @@
+package storekit
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy controls how the verification client retries transient App
+// Store failures: network errors, non-200 HTTP responses, statuses 21002, 21005
+// and 21009, and statuses 21100-21199 flagged with is-retryable.
+//
+// Backoff between attempts grows exponentially from InitialBackoff by
+// Multiplier up to MaxBackoff, and each wait is randomly spread by Jitter.
+// Waiting stops as soon as the request context is done.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts made against an App Store
+	// endpoint, including the first one. Values below 2 disable retries.
+	MaxAttempts int
+
+	// InitialBackoff is the wait before the first retry.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the wait between any two attempts.
+	MaxBackoff time.Duration
+
+	// Multiplier is the factor the backoff grows by after each retry. Values
+	// below 1 are treated as 1.
+	Multiplier float64
+
+	// Jitter is the fraction (0 to 1) of each backoff that is randomized, so that
+	// clients failing together don't retry together.
+	Jitter float64
+}
+
+// DefaultRetryPolicy is used by clients unless configured with WithRetryPolicy.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 250 * time.Millisecond,
+	MaxBackoff:     2 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
+// NoRetry makes exactly one attempt per App Store endpoint.
+var NoRetry = RetryPolicy{MaxAttempts: 1}
+
+// backoff returns the wait before the given retry, counting from 1.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	d := float64(p.InitialBackoff)
+	for i := 1; i < retry; i++ {
+		d *= multiplier
+		if p.MaxBackoff > 0 && d >= float64(p.MaxBackoff) {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+
+	jitter := p.Jitter
+	if jitter > 1 {
+		jitter = 1
+	}
+	if jitter > 0 {
+		// Spread evenly over [d-d*jitter, d+d*jitter).
+		d += d * jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(d)
+}
+
+// wait blocks for the backoff before the given retry. It returns false without
+// waiting the full duration if ctx is done first, or would be done before the
+// backoff elapses.
+func (p RetryPolicy) wait(ctx context.Context, retry int) bool {
+	d := p.backoff(retry)
+
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		return false
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
@@ -0,0 +1,145 @@
+package storekit
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// App is how a Router verifies the receipts of one app.
+type App struct {
+	// BundleID is the bundle identifier of the app, as in Receipt.BundleId.
+	BundleID string
+
+	// Verifier verifies the app's receipts. Use a client configured with the
+	// app's environment, shared secrets and policies.
+	Verifier Verifier
+
+	// Password is set on requests without a password of their own. Leave it
+	// empty if Verifier has shared secrets configured.
+	Password string
+}
+
+// ErrUnknownBundleID matches any *UnknownBundleIDError with errors.Is.
+var ErrUnknownBundleID = errors.New("receipt is for an unknown bundle id")
+
+// UnknownBundleIDError is returned by a Router for a receipt of an app it
+// doesn't serve.
+type UnknownBundleIDError struct {
+	// BundleID is the bundle ID of the receipt, if it could be decoded.
+	BundleID string
+}
+
+func (e *UnknownBundleIDError) Error() string {
+	if e.BundleID == "" {
+		return "receipt has no bundle id"
+	}
+	return "receipt is for unknown bundle id " + e.BundleID
+}
+
+func (e *UnknownBundleIDError) Is(target error) bool {
+	return target == ErrUnknownBundleID
+}
+
+// BundleIDMismatchError is returned by a Router when a receipt verified for an
+// app turns out to belong to another one.
+type BundleIDMismatchError struct {
+	// Expected is the bundle ID the receipt was verified for.
+	Expected string
+
+	// BundleID is the bundle ID of the receipt.
+	BundleID string
+}
+
+func (e *BundleIDMismatchError) Error() string {
+	return "receipt is for bundle id " + e.BundleID + ", not " + e.Expected
+}
+
+// Router verifies receipts of several apps through one backend, picking the
+// password and verifier of the app each receipt belongs to, and rejecting
+// receipts of apps it doesn't serve.
+type Router struct {
+	discovery Verifier
+	apps      map[string]App
+}
+
+// NewRouter returns a Router serving apps. discovery is used by Verify to
+// decode receipts whose bundle ID isn't known up front; it only needs to get
+// the receipt decoded, so a client without shared secrets will do.
+func NewRouter(discovery Verifier, apps ...App) *Router {
+	r := &Router{
+		discovery: discovery,
+		apps:      make(map[string]App, len(apps)),
+	}
+	for _, app := range apps {
+		r.apps[app.BundleID] = app
+	}
+	return r
+}
+
+// Verify finds out which app the receipt belongs to by decoding it with the
+// discovery verifier, then verifies it as that app. Prefer VerifyApp when the
+// bundle ID is known, e.g. sent by the device, to save an App Store call.
+func (r *Router) Verify(ctx context.Context, req *ReceiptRequest) (*VerifyResult, error) {
+	if req == nil {
+		return &VerifyResult{SharedSecretIndex: -1}, ErrNilRequest
+	}
+
+	result, err := r.discovery.Verify(ctx, req)
+	if result.Response == nil {
+		return result, err
+	}
+
+	bundleID := result.Response.Receipt.BundleId
+	if _, ok := r.apps[bundleID]; !ok {
+		if bundleID == "" && err != nil {
+			// Nothing decoded to route on.
+			return result, err
+		}
+		return result, &UnknownBundleIDError{BundleID: bundleID}
+	}
+
+	return r.VerifyApp(ctx, bundleID, req)
+}
+
+func (r *Router) checkSandboxPolicy(ctx context.Context, req *ReceiptRequest, result *VerifyResult) error {
+	if result.Response == nil {
+		return nil
+	}
+	app, ok := r.apps[result.Response.Receipt.BundleId]
+	if !ok {
+		return nil
+	}
+	return checkSandboxPolicy(ctx, app.Verifier, req, result)
+}
+
+// VerifyApp verifies the receipt as the app with the given bundle ID, and
+// checks that the receipt does belong to that app.
+func (r *Router) VerifyApp(ctx context.Context, bundleID string, req *ReceiptRequest) (*VerifyResult, error) {
+	if req == nil {
+		return &VerifyResult{SharedSecretIndex: -1}, ErrNilRequest
+	}
+
+	app, ok := r.apps[bundleID]
+	if !ok {
+		return &VerifyResult{SharedSecretIndex: -1}, &UnknownBundleIDError{BundleID: bundleID}
+	}
+
+	if req.Password == "" && app.Password != "" {
+		appReq := *req
+		appReq.Password = app.Password
+		req = &appReq
+	}
+
+	result, err := app.Verifier.Verify(ctx, req)
+	if result.Response != nil {
+		if actual := result.Response.Receipt.BundleId; actual != "" && actual != bundleID {
+			if _, ok := r.apps[actual]; !ok {
+				return result, &UnknownBundleIDError{BundleID: actual}
+			}
+			return result, &BundleIDMismatchError{Expected: bundleID, BundleID: actual}
+		}
+	}
+
+	return result, err
+}
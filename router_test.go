@@ -0,0 +1,110 @@
+package storekit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// bundleVerifier returns a verifier decoding every receipt as one of the app
+// with the given bundle ID, and recording the passwords it was sent.
+func bundleVerifier(bundleID string, err error, passwords *[]string) Verifier {
+	return VerifierFunc(func(ctx context.Context, req *ReceiptRequest) (*VerifyResult, error) {
+		if passwords != nil {
+			*passwords = append(*passwords, req.Password)
+		}
+		resp := &ReceiptResponse{Receipt: Receipt{BundleId: bundleID}}
+		return &VerifyResult{Response: resp, SharedSecretIndex: -1}, err
+	})
+}
+
+func TestRouterSetsAppPassword(t *testing.T) {
+	var passwords []string
+	r := NewRouter(nil,
+		App{BundleID: "com.example.a", Verifier: bundleVerifier("com.example.a", nil, &passwords), Password: "secret-a"},
+	)
+
+	for _, password := range []string{"", "own"} {
+		req := &ReceiptRequest{ReceiptData: []byte("receipt"), Password: password}
+		if _, err := r.VerifyApp(context.Background(), "com.example.a", req); err != nil {
+			t.Fatalf("VerifyApp() error = %v", err)
+		}
+		if req.Password != password {
+			t.Errorf("VerifyApp() changed the caller's password to %q", req.Password)
+		}
+	}
+	if want := []string{"secret-a", "own"}; len(passwords) != 2 || passwords[0] != want[0] || passwords[1] != want[1] {
+		t.Errorf("passwords = %q; want %q", passwords, want)
+	}
+}
+
+func TestRouterRejectsOtherApps(t *testing.T) {
+	r := NewRouter(nil,
+		App{BundleID: "com.example.a", Verifier: bundleVerifier("com.example.b", nil, nil)},
+		App{BundleID: "com.example.b", Verifier: bundleVerifier("com.example.b", nil, nil)},
+		App{BundleID: "com.example.c", Verifier: bundleVerifier("com.example.evil", nil, nil)},
+	)
+
+	tests := []struct {
+		bundleID string
+		want     error
+	}{
+		{"com.example.b", nil},
+		{"com.example.a", &BundleIDMismatchError{Expected: "com.example.a", BundleID: "com.example.b"}},
+		{"com.example.c", &UnknownBundleIDError{BundleID: "com.example.evil"}},
+		{"com.example.unknown", &UnknownBundleIDError{BundleID: "com.example.unknown"}},
+	}
+	for _, tt := range tests {
+		_, err := r.VerifyApp(context.Background(), tt.bundleID, &ReceiptRequest{})
+		if tt.want == nil && err != nil || tt.want != nil && (err == nil || err.Error() != tt.want.Error()) {
+			t.Errorf("VerifyApp(%q) error = %v; want %v", tt.bundleID, err, tt.want)
+		}
+		if _, unknown := tt.want.(*UnknownBundleIDError); errors.Is(err, ErrUnknownBundleID) != unknown {
+			t.Errorf("VerifyApp(%q) error = %v; errors.Is(ErrUnknownBundleID) should be %t", tt.bundleID, err, unknown)
+		}
+	}
+}
+
+func TestRouterDiscovery(t *testing.T) {
+	failure := &StatusError{Status: ReceiptResponseStatusDataMalformed}
+	app := App{BundleID: "com.example.a", Verifier: bundleVerifier("com.example.a", nil, nil)}
+
+	tests := []struct {
+		name      string
+		discovery Verifier
+		want      error
+	}{
+		{"known", bundleVerifier("com.example.a", failure, nil), nil},
+		{"unknown", bundleVerifier("com.example.b", nil, nil), &UnknownBundleIDError{BundleID: "com.example.b"}},
+		{"undecoded", bundleVerifier("", failure, nil), failure},
+		{"no bundle id", bundleVerifier("", nil, nil), &UnknownBundleIDError{}},
+	}
+	for _, tt := range tests {
+		_, err := NewRouter(tt.discovery, app).Verify(context.Background(), &ReceiptRequest{})
+		if tt.want == nil && err != nil || tt.want != nil && (err == nil || err.Error() != tt.want.Error()) {
+			t.Errorf("%s: Verify() error = %v; want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestRouterSandboxPolicyWhenWrapped(t *testing.T) {
+	wrappers := map[string]func(Verifier) Verifier{
+		"deduplicator": func(v Verifier) Verifier { return NewDeduplicator(v) },
+		"cache":        func(v Verifier) Verifier { return NewCachingVerifier(v, NewLRUCache(10), time.Hour) },
+	}
+	for name, wrap := range wrappers {
+		c, sandbox := newSandboxPolicyClient(t)
+		sandbox.BundleID = "com.example.a"
+		v := wrap(NewRouter(c, App{BundleID: "com.example.a", Verifier: c}))
+		req := &ReceiptRequest{ReceiptData: []byte("receipt")}
+
+		for _, userID := range []string{"other", "reviewer", "other"} {
+			_, err := v.Verify(ContextWithUserID(context.Background(), userID), req)
+			if rejected := errors.Is(err, ErrSandboxReceiptRejected); rejected != (userID == "other") {
+				t.Errorf("%s: %s: Verify() error = %v", name, userID, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package storekit
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// SandboxPolicy decides whether a sandbox receipt is acceptable to a client
+// verifying in production. Such receipts reach the sandbox through the
+// environment auto fix (21007), and come from App Review and TestFlight as
+// well as from anyone holding a sandbox account.
+//
+// Whatever the policy, VerifyResult.Sandbox tells sandbox receipts apart, so
+// that they never grant real entitlements by accident.
+//
+// Layers that share results between callers, such as Deduplicator and
+// CachingVerifier, apply the policy of the verifier they wrap to each caller,
+// with the caller's context. Verifiers of your own that share results must do
+// the same, or the policy sees the context of whoever started the call.
+type SandboxPolicy interface {
+	// AllowSandbox is called with the request and the successful sandbox
+	// response.
+	AllowSandbox(ctx context.Context, req *ReceiptRequest, resp *ReceiptResponse) bool
+}
+
+// SandboxPolicyFunc adapts an ordinary function to the SandboxPolicy
+// interface.
+type SandboxPolicyFunc func(ctx context.Context, req *ReceiptRequest, resp *ReceiptResponse) bool
+
+func (f SandboxPolicyFunc) AllowSandbox(ctx context.Context, req *ReceiptRequest, resp *ReceiptResponse) bool {
+	return f(ctx, req, resp)
+}
+
+// DenySandbox rejects all sandbox receipts. Note that App Review makes its
+// purchases in the sandbox, so denying them all may get an app rejected.
+var DenySandbox SandboxPolicy = SandboxPolicyFunc(func(context.Context, *ReceiptRequest, *ReceiptResponse) bool {
+	return false
+})
+
+// AllowSandboxApplicationVersions allows sandbox receipts of the given app
+// versions, e.g. the versions currently under App Review.
+func AllowSandboxApplicationVersions(versions ...string) SandboxPolicy {
+	allowed := make(map[string]bool, len(versions))
+	for _, version := range versions {
+		allowed[version] = true
+	}
+	return SandboxPolicyFunc(func(ctx context.Context, req *ReceiptRequest, resp *ReceiptResponse) bool {
+		return allowed[resp.Receipt.ApplicationVersion]
+	})
+}
+
+// AllowSandboxUsers allows sandbox receipts verified on behalf of the given
+// users. The user is taken from the context passed to Verify; see
+// ContextWithUserID.
+func AllowSandboxUsers(userIDs ...string) SandboxPolicy {
+	allowed := make(map[string]bool, len(userIDs))
+	for _, userID := range userIDs {
+		allowed[userID] = true
+	}
+	return SandboxPolicyFunc(func(ctx context.Context, req *ReceiptRequest, resp *ReceiptResponse) bool {
+		userID, ok := UserIDFromContext(ctx)
+		return ok && allowed[userID]
+	})
+}
+
+// AnySandboxPolicy allows sandbox receipts allowed by any of the policies.
+func AnySandboxPolicy(policies ...SandboxPolicy) SandboxPolicy {
+	return SandboxPolicyFunc(func(ctx context.Context, req *ReceiptRequest, resp *ReceiptResponse) bool {
+		for _, policy := range policies {
+			if policy.AllowSandbox(ctx, req, resp) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// sandboxPolicyChecker is implemented by the verifiers that apply a sandbox
+// policy, directly or through the verifiers they wrap.
+type sandboxPolicyChecker interface {
+	// checkSandboxPolicy returns ErrSandboxReceiptRejected if the policy rejects
+	// the successful result.
+	checkSandboxPolicy(ctx context.Context, req *ReceiptRequest, result *VerifyResult) error
+}
+
+type deferSandboxPolicyKey struct{}
+
+// deferSandboxPolicy returns a copy of ctx for a call to v whose result is
+// shared between callers. The sandbox policy of v is then left to
+// checkSandboxPolicy, for each caller.
+func deferSandboxPolicy(ctx context.Context, v Verifier) context.Context {
+	if _, ok := v.(sandboxPolicyChecker); !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, deferSandboxPolicyKey{}, true)
+}
+
+// checkSandboxPolicy applies the sandbox policy of v, if any, to the result of
+// a call made with deferSandboxPolicy. It does nothing if ctx defers the policy
+// to an outer layer.
+func checkSandboxPolicy(ctx context.Context, v Verifier, req *ReceiptRequest, result *VerifyResult) error {
+	if sandboxPolicyDeferred(ctx) {
+		return nil
+	}
+	if checker, ok := v.(sandboxPolicyChecker); ok {
+		return checker.checkSandboxPolicy(ctx, req, result)
+	}
+	return nil
+}
+
+func sandboxPolicyDeferred(ctx context.Context) bool {
+	deferred, _ := ctx.Value(deferSandboxPolicyKey{}).(bool)
+	return deferred
+}
+
+type userIDKey struct{}
+
+// ContextWithUserID returns a copy of ctx carrying the ID of the user whose
+// receipt is being verified, for sandbox policies to use.
+func ContextWithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userIDKey{}, userID)
+}
+
+// UserIDFromContext returns the user ID set by ContextWithUserID.
+func UserIDFromContext(ctx context.Context) (string, bool) {
+	userID, ok := ctx.Value(userIDKey{}).(string)
+	return userID, ok
+}
+
+// ErrSandboxReceiptRejected is returned when a production client's sandbox
+// policy rejects a sandbox receipt. The result still holds the sandbox
+// response.
+var ErrSandboxReceiptRejected = errors.New("sandbox receipt rejected by policy")
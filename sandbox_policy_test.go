@@ -0,0 +1,137 @@
+package storekit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// newSandboxPolicyClient returns a production client that finds receipts in the
+// sandbox, and allows them for user "reviewer" only. The sandbox leaves out the
+// optional environment of its responses.
+func newSandboxPolicyClient(t *testing.T) (*Client, *appStore) {
+	return newSandboxPolicyClientWith(t, AllowSandboxUsers("reviewer"))
+}
+
+func newSandboxPolicyClientWith(t *testing.T, policy SandboxPolicy) (*Client, *appStore) {
+	production := newAppStore(t, ReceiptResponseStatusSandboxReceiptSentToProduction)
+	sandbox := newAppStore(t, ReceiptResponseStatusOK)
+
+	c, err := NewClient(
+		WithProductionURL(production.URL),
+		WithSandboxURL(sandbox.URL),
+		WithSandboxPolicy(policy),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, sandbox
+}
+
+func TestDeduplicatorAppliesSandboxPolicyPerCaller(t *testing.T) {
+	c, sandbox := newSandboxPolicyClient(t)
+	sandbox.Hold = make(chan struct{})
+	d := NewDeduplicator(c)
+	req := &ReceiptRequest{ReceiptData: []byte("receipt")}
+
+	type outcome struct {
+		result *VerifyResult
+		err    error
+	}
+	verify := func(userID string) <-chan outcome {
+		done := make(chan outcome, 1)
+		go func() {
+			result, err := d.Verify(ContextWithUserID(context.Background(), userID), req)
+			done <- outcome{result, err}
+		}()
+		return done
+	}
+
+	reviewer := verify("reviewer")
+	<-sandbox.Received
+	other := verify("other")
+	waitForWaiters(t, d, req, 2)
+	close(sandbox.Hold)
+
+	if o := <-reviewer; o.err != nil {
+		t.Errorf("reviewer: Verify() error = %v", o.err)
+	}
+	if o := <-other; !errors.Is(o.err, ErrSandboxReceiptRejected) || !o.result.Shared {
+		t.Errorf("other: Verify() error = %v, shared = %t; want ErrSandboxReceiptRejected, true", o.err, o.result.Shared)
+	}
+}
+
+func TestCachingVerifierAppliesSandboxPolicyToCachedResults(t *testing.T) {
+	c, _ := newSandboxPolicyClient(t)
+	v := NewCachingVerifier(c, NewLRUCache(10), time.Hour)
+	req := &ReceiptRequest{ReceiptData: []byte("receipt")}
+
+	if _, err := v.Verify(ContextWithUserID(context.Background(), "other"), req); !errors.Is(err, ErrSandboxReceiptRejected) {
+		t.Errorf("other: Verify() error = %v; want ErrSandboxReceiptRejected", err)
+	}
+	if result, err := v.Verify(ContextWithUserID(context.Background(), "reviewer"), req); err != nil || !result.Cached {
+		t.Errorf("reviewer: Verify() error = %v, cached = %t; want nil, true", err, result.Cached)
+	}
+	if result, err := v.Verify(ContextWithUserID(context.Background(), "other"), req); !errors.Is(err, ErrSandboxReceiptRejected) || !result.Cached {
+		t.Errorf("other: Verify() error = %v, cached = %t; want ErrSandboxReceiptRejected, true", err, result.Cached)
+	}
+}
+
+func TestCachingVerifierRestoresEnvironment(t *testing.T) {
+	c, _ := newSandboxPolicyClientWith(t, DenySandbox)
+	v := NewCachingVerifier(c, NewLRUCache(10), time.Hour)
+	req := &ReceiptRequest{ReceiptData: []byte("receipt")}
+
+	for _, cached := range []bool{false, true} {
+		result, err := v.Verify(context.Background(), req)
+		if !errors.Is(err, ErrSandboxReceiptRejected) || result.Cached != cached {
+			t.Errorf("Verify() error = %v, cached = %t; want ErrSandboxReceiptRejected, %t", err, result.Cached, cached)
+		}
+		if result.Environment != EnvironmentSandbox || !result.Sandbox {
+			t.Errorf("Verify() environment = %q, sandbox = %t; want Sandbox, true", result.Environment, result.Sandbox)
+		}
+	}
+}
+
+func TestCachingVerifierSkipsUnknownEnvironment(t *testing.T) {
+	calls := 0
+	verifier := VerifierFunc(func(ctx context.Context, req *ReceiptRequest) (*VerifyResult, error) {
+		calls++
+		body := []byte(`{"status":0}`)
+		return &VerifyResult{Body: body, Response: &ReceiptResponse{}}, nil
+	})
+	v := NewCachingVerifier(verifier, NewLRUCache(10), time.Hour)
+
+	for i := 0; i < 2; i++ {
+		if result, err := v.Verify(context.Background(), &ReceiptRequest{}); err != nil || result.Cached {
+			t.Errorf("Verify() error = %v, cached = %t; want nil, false", err, result.Cached)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("verifier called %d times; want 2", calls)
+	}
+}
+
+// waitForWaiters waits until n callers wait on the deduplicated call for req.
+func waitForWaiters(t *testing.T, d *Deduplicator, req *ReceiptRequest, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		d.mu.Lock()
+		waiters := 0
+		if call, ok := d.calls[requestKey(req)]; ok {
+			waiters = call.waiters
+		}
+		d.mu.Unlock()
+
+		if waiters == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d callers waiting; want %d", waiters, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
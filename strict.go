@@ -0,0 +1,270 @@
+package storekit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DecodeWarningKind is the kind of schema drift a DecodeWarning reports.
+type DecodeWarningKind string
+
+const (
+	// DecodeWarningUnknownField is a key that matches no field of the model.
+	DecodeWarningUnknownField DecodeWarningKind = "unknown_field"
+
+	// DecodeWarningTypeMismatch is a value of another JSON type than the model
+	// expects, e.g. a number where Apple documents a string.
+	DecodeWarningTypeMismatch DecodeWarningKind = "type_mismatch"
+
+	// DecodeWarningMissingField is a required key that is absent.
+	DecodeWarningMissingField DecodeWarningKind = "missing_field"
+)
+
+// DecodeWarning reports a difference between a JSON document and the model it
+// is decoded into.
+type DecodeWarning struct {
+	Kind DecodeWarningKind
+
+	// Path locates the value in the document, e.g.
+	// "latest_receipt_info[0].expires_date_ms".
+	Path string
+
+	// Expected and Actual are the expected and actual JSON types of the value
+	// for a DecodeWarningTypeMismatch, e.g. "string" and "number".
+	Expected, Actual string
+}
+
+func (w DecodeWarning) String() string {
+	switch w.Kind {
+	case DecodeWarningTypeMismatch:
+		return w.Path + ": expected " + w.Expected + ", got " + w.Actual
+	case DecodeWarningMissingField:
+		return w.Path + ": missing"
+	default:
+		return w.Path + ": unknown field"
+	}
+}
+
+// requiredFields lists the keys the App Store always sends for a model.
+var requiredFields = map[reflect.Type][]string{
+	reflect.TypeOf(ReceiptResponse{}):      {"status"},
+	reflect.TypeOf(Receipt{}):              {"bundle_id", "in_app"},
+	reflect.TypeOf(InAppPurchaseReceipt{}): {"original_transaction_id", "product_id", "purchase_date_ms", "transaction_id"},
+	reflect.TypeOf(LatestReceiptInfo{}):    {"original_transaction_id", "product_id", "purchase_date_ms", "transaction_id"},
+	reflect.TypeOf(PendingRenewalInfo{}):   {"auto_renew_status", "original_transaction_id", "product_id"},
+	reflect.TypeOf(Notification{}):         {"notification_type", "unified_receipt"},
+	reflect.TypeOf(UnifiedReceipt{}):       {"environment", "status"},
+}
+
+// DecodeStrict decodes data into v, a *ReceiptResponse or *Notification, like
+// the client does, and also reports unknown fields, type mismatches and missing
+// required fields as warnings. Run it in shadow of regular decoding to detect
+// App Store API changes before they break parsing.
+//
+// The error is that of regular decoding; warnings are reported even if it
+// fails.
+func DecodeStrict(data []byte, v interface{}) ([]DecodeWarning, error) {
+	t := reflect.TypeOf(v)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return nil, errors.New("storekit: DecodeStrict needs a pointer to a struct")
+	}
+
+	warnings, err := schemaWarnings(data, t.Elem())
+	if err != nil {
+		return nil, &DecodeError{Err: err}
+	}
+
+	if err := json.Unmarshal(dropControlChars(data), v); err != nil {
+		return warnings, &DecodeError{Err: err}
+	}
+
+	return warnings, nil
+}
+
+// schemaWarnings compares the JSON document data with the model type t.
+func schemaWarnings(data []byte, t reflect.Type) ([]DecodeWarning, error) {
+	dec := json.NewDecoder(bytes.NewReader(dropControlChars(data)))
+	dec.UseNumber()
+
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	var warnings []DecodeWarning
+	checkSchema(doc, t, false, "", &warnings)
+	return warnings, nil
+}
+
+func checkSchema(value interface{}, t reflect.Type, stringTag bool, path string, warnings *[]DecodeWarning) {
+	if value == nil {
+		// null is accepted for any field.
+		return
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	expected := expectedJSONType(t, stringTag)
+	if actual := jsonType(value); actual != expected {
+		*warnings = append(*warnings, DecodeWarning{
+			Kind:     DecodeWarningTypeMismatch,
+			Path:     path,
+			Expected: expected,
+			Actual:   actual,
+		})
+		return
+	}
+
+	switch v := value.(type) {
+	case []interface{}:
+		for i, elem := range v {
+			checkSchema(elem, t.Elem(), false, path+"["+strconv.Itoa(i)+"]", warnings)
+		}
+	case map[string]interface{}:
+		if t.Kind() == reflect.Struct {
+			checkStruct(v, t, path, warnings)
+		}
+	}
+}
+
+func checkStruct(object map[string]interface{}, t reflect.Type, path string, warnings *[]DecodeWarning) {
+	prefix := path
+	if prefix != "" {
+		prefix += "."
+	}
+
+	fields := jsonFields(t)
+
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		field, ok := fields[strings.ToLower(key)]
+		if !ok {
+			*warnings = append(*warnings, DecodeWarning{
+				Kind: DecodeWarningUnknownField,
+				Path: prefix + key,
+			})
+			continue
+		}
+		checkSchema(object[key], field.Type, field.stringTag, prefix+key, warnings)
+	}
+
+	for _, key := range requiredFields[t] {
+		if _, ok := object[key]; !ok {
+			*warnings = append(*warnings, DecodeWarning{
+				Kind: DecodeWarningMissingField,
+				Path: prefix + key,
+			})
+		}
+	}
+}
+
+type jsonField struct {
+	reflect.StructField
+	stringTag bool
+}
+
+// jsonFields returns the fields of struct type t by lowercased JSON key.
+func jsonFields(t reflect.Type) map[string]jsonField {
+	fields := map[string]jsonField{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := field.Name
+		var opts []string
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			if tag == "-" {
+				continue
+			}
+			parts := strings.Split(tag, ",")
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			opts = parts[1:]
+		}
+
+		f := jsonField{StructField: field}
+		for _, opt := range opts {
+			if opt == "string" {
+				f.stringTag = true
+			}
+		}
+		fields[strings.ToLower(name)] = f
+	}
+	return fields
+}
+
+// expectedJSONType returns the JSON type a value of type t is documented as.
+func expectedJSONType(t reflect.Type, stringTag bool) string {
+	if t == reflect.TypeOf(Timestamp(0)) || t == reflect.TypeOf(Flag{}) {
+		// Decoded from numbers and booleans too, but documented as strings.
+		return "string"
+	}
+
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map:
+		return "object"
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "string" // Base64.
+		}
+		return "array"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		if stringTag {
+			return "string"
+		}
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if stringTag {
+			return "string"
+		}
+		return "number"
+	default:
+		return "any"
+	}
+}
+
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return "null"
+	}
+}
+
+// reportSchemaDrift compares an App Store response body with the
+// ReceiptResponse model for the client's strict decoding reporter.
+func (c *Client) reportSchemaDrift(ctx context.Context, body []byte) {
+	warnings, err := schemaWarnings(body, reflect.TypeOf(ReceiptResponse{}))
+	if err == nil && len(warnings) > 0 {
+		c.strictDecoding(ctx, warnings)
+	}
+}
@@ -0,0 +1,130 @@
+package storekit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestDecodeStrict(t *testing.T) {
+	const purchase = `"original_transaction_id":"1","transaction_id":"1","purchase_date_ms":"1592592000000"`
+
+	tests := []struct {
+		name    string
+		v       interface{}
+		data    string
+		want    []DecodeWarning
+		wantErr bool
+	}{
+		{
+			name: "matching",
+			v:    &ReceiptResponse{},
+			data: `{"status":0,"latest_receipt_info":[{"product_id":"p",` + purchase + `}]}`,
+		},
+		{
+			name: "unknown field",
+			v:    &ReceiptResponse{},
+			data: `{"status":0,"latest_receipt_info":[{"product_id":"p","new_field":true,` + purchase + `}]}`,
+			want: []DecodeWarning{
+				{Kind: DecodeWarningUnknownField, Path: "latest_receipt_info[0].new_field"},
+			},
+		},
+		{
+			name: "number for a string tagged field",
+			v:    &ReceiptResponse{},
+			data: `{"status":0,"latest_receipt_info":[{"product_id":"p","quantity":1,` + purchase + `}]}`,
+			want: []DecodeWarning{
+				{Kind: DecodeWarningTypeMismatch, Path: "latest_receipt_info[0].quantity", Expected: "string", Actual: "number"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing field",
+			v:    &ReceiptResponse{},
+			data: `{"status":0,"latest_receipt_info":[{` + purchase + `}]}`,
+			want: []DecodeWarning{
+				{Kind: DecodeWarningMissingField, Path: "latest_receipt_info[0].product_id"},
+			},
+		},
+		{
+			name: "notification",
+			v:    &Notification{},
+			data: `{"notification_type":"DID_RENEW","password":"secret","unified_receipt":{"status":0}}`,
+			want: []DecodeWarning{
+				{Kind: DecodeWarningMissingField, Path: "unified_receipt.environment"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		got, err := DecodeStrict([]byte(tt.data), tt.v)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: DecodeStrict() warnings = %v; want %v", tt.name, got, tt.want)
+		}
+		if _, ok := err.(*DecodeError); ok != tt.wantErr || (err != nil && !ok) {
+			t.Errorf("%s: DecodeStrict() error = %v; want a *DecodeError: %t", tt.name, err, tt.wantErr)
+		}
+	}
+
+	if _, err := DecodeStrict([]byte(`{}`), ReceiptResponse{}); err == nil {
+		t.Error("DecodeStrict() of a non-pointer succeeded")
+	}
+}
+
+func TestStrictDecodingReporter(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		maxSize int64
+		want    []DecodeWarning
+		wantErr error
+	}{
+		{
+			name: "decode error",
+			body: `{"status":"0"}`,
+			want: []DecodeWarning{
+				{Kind: DecodeWarningTypeMismatch, Path: "status", Expected: "number", Actual: "string"},
+			},
+		},
+		{
+			name:    "too large",
+			body:    `{"status":"0","padding":"` + string(make([]byte, 1024)) + `"}`,
+			maxSize: 512,
+			wantErr: ErrResponseTooLarge,
+		},
+	}
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(tt.body))
+		}))
+		defer server.Close()
+
+		var got []DecodeWarning
+		opts := []Option{
+			WithProductionURL(server.URL),
+			WithRetryPolicy(RetryPolicy{MaxAttempts: 1}),
+			WithStrictDecoding(func(ctx context.Context, warnings []DecodeWarning) {
+				got = append(got, warnings...)
+			}),
+		}
+		if tt.maxSize > 0 {
+			opts = append(opts, WithMaxResponseSize(tt.maxSize))
+		}
+		c, err := NewClient(opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = c.Verify(context.Background(), &ReceiptRequest{ReceiptData: []byte("receipt")})
+		var decodeErr *DecodeError
+		if !errors.As(err, &decodeErr) || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: Verify() error = %v; want a *DecodeError", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: reported warnings = %v; want %v", tt.name, got, tt.want)
+		}
+	}
+}
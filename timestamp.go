@@ -0,0 +1,87 @@
+package storekit
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Timestamp is a time in UNIX epoch time format, in milliseconds, as in the
+// *_ms fields of App Store responses and notifications.
+//
+// Apple documents these as strings, but a Timestamp also accepts JSON numbers
+// and empty strings (as zero) when decoding. It is always encoded back as a
+// string.
+//
+// So the *_ms fields don't round-trip exactly: numbers come back as strings,
+// and empty strings, "0" and null decode as zero, which the models omit when
+// encoding.
+type Timestamp int64
+
+// TimestampOf returns the Timestamp of t, truncated to the millisecond.
+func TimestampOf(t time.Time) Timestamp {
+	if t.IsZero() {
+		return 0
+	}
+	return Timestamp(t.UnixNano() / int64(time.Millisecond))
+}
+
+// Time returns the timestamp as a time in UTC, or the zero time if the
+// timestamp is zero (absent).
+func (t Timestamp) Time() time.Time {
+	if t == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(t)*int64(time.Millisecond)).UTC()
+}
+
+// IsZero reports whether the timestamp is zero, which is the case when the
+// field is absent.
+func (t Timestamp) IsZero() bool {
+	return t == 0
+}
+
+// MarshalJSON implements json.Marshaler, encoding the timestamp as a string of
+// milliseconds like the App Store does.
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + strconv.FormatInt(int64(t), 10) + `"`), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler, accepting milliseconds as a string
+// or a number. Empty strings and null decode as zero.
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if string(data) == "null" {
+		return nil
+	}
+
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		data = []byte(s)
+		if len(data) == 0 {
+			*t = 0
+			return nil
+		}
+	}
+
+	ms, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		// Tolerate numbers in floating point notation, e.g. 1.5e12, as long as
+		// they fit.
+		f, ferr := strconv.ParseFloat(string(data), 64)
+		if ferr != nil || math.IsNaN(f) || f < math.MinInt64 || f >= -math.MinInt64 {
+			return errors.Errorf("invalid millisecond timestamp %s", data)
+		}
+		ms = int64(f)
+	}
+
+	*t = Timestamp(ms)
+	return nil
+}
@@ -0,0 +1,44 @@
+package storekit
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestTimestampRoundTrip(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{`{"expires_date_ms":"1592601600000"}`, `{"expires_date_ms":"1592601600000"}`},
+		{`{"expires_date_ms":1592601600000}`, `{"expires_date_ms":"1592601600000"}`},
+		{`{"expires_date_ms":1.5926016e12}`, `{"expires_date_ms":"1592601600000"}`},
+		// Documented losses: zero values are omitted.
+		{`{"expires_date_ms":""}`, `{}`},
+		{`{"expires_date_ms":"0"}`, `{}`},
+		{`{"expires_date_ms":null}`, `{}`},
+	}
+	for _, tt := range tests {
+		var info InAppPurchaseReceipt
+		if err := json.Unmarshal([]byte(tt.in), &info); err != nil {
+			t.Errorf("Unmarshal(%s) error = %v", tt.in, err)
+			continue
+		}
+		out, err := json.Marshal(info)
+		if err != nil {
+			t.Errorf("Marshal() error = %v", err)
+		} else if string(out) != tt.out {
+			t.Errorf("round trip of %s = %s; want %s", tt.in, out, tt.out)
+		}
+	}
+}
+
+func TestTimestampRejectsInvalidValues(t *testing.T) {
+	for _, data := range []string{`"soon"`, `true`, `{}`, `"NaN"`, `"Inf"`, `"-Inf"`, `1e300`, `-1e300`, `9223372036854775808.0`} {
+		var ts Timestamp
+		err := json.Unmarshal([]byte(data), &ts)
+		if err == nil || !strings.HasPrefix(err.Error(), "invalid millisecond timestamp") {
+			t.Errorf("Unmarshal(%s) = %d, %v; want an invalid millisecond timestamp error", data, ts, err)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package storekit
+
+import (
+	"context"
+)
+
+// Tracer starts spans for receipt verification. Its methods mirror the
+// OpenTelemetry tracing API, so an OpenTelemetry tracer can back it with a thin
+// adapter.
+//
+// The client starts a "storekit.Verify" span for every Verify call, and a
+// "storekit.Attempt" child span for every request made to the App Store within
+// it, including retries and the environment auto fix.
+type Tracer interface {
+	Start(ctx context.Context, spanName string) (context.Context, Span)
+}
+
+// Span is a single traced operation started by a Tracer.
+type Span interface {
+	SetAttributes(attributes ...Attribute)
+	RecordError(err error)
+	End()
+}
+
+// Attribute is a key-value pair describing a span. Value is a string, bool,
+// int or int64.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Attribute keys set on spans started by the client.
+const (
+	AttributeEnvironment          = "storekit.environment"
+	AttributeEnvironmentAutoFixed = "storekit.environment_auto_fixed"
+	AttributeAttempt              = "storekit.attempt"
+	AttributeAttempts             = "storekit.attempts"
+	AttributeStatus               = "storekit.status"
+	AttributeIsRetryable          = "storekit.is_retryable"
+	AttributeBundleID             = "storekit.bundle_id"
+	AttributeHTTPURL              = "http.url"
+	AttributeHTTPStatusCode       = "http.status_code"
+)
+
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, spanName string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(attributes ...Attribute) {}
+func (noopSpan) RecordError(err error)                 {}
+func (noopSpan) End()                                  {}
+
+// endVerifySpan describes the outcome of a Verify call on its span and ends it.
+func endVerifySpan(span Span, result *VerifyResult, err error) {
+	span.SetAttributes(
+		Attribute{AttributeEnvironment, string(result.Environment)},
+		Attribute{AttributeEnvironmentAutoFixed, result.EnvironmentAutoFixed},
+		Attribute{AttributeAttempts, len(result.Attempts)},
+	)
+	setResponseAttributes(span, result.Response)
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+// endAttemptSpan describes a request made to the App Store on its span and ends
+// it.
+func endAttemptSpan(span Span, attempt *Attempt, resp *ReceiptResponse) {
+	span.SetAttributes(
+		Attribute{AttributeEnvironment, string(attempt.Environment)},
+		Attribute{AttributeAttempt, attempt.Number},
+		Attribute{AttributeHTTPURL, attempt.URL},
+	)
+	if attempt.HTTPStatusCode != 0 {
+		span.SetAttributes(Attribute{AttributeHTTPStatusCode, attempt.HTTPStatusCode})
+	}
+	setResponseAttributes(span, resp)
+	if attempt.Err != nil {
+		span.RecordError(attempt.Err)
+	}
+	span.End()
+}
+
+func setResponseAttributes(span Span, resp *ReceiptResponse) {
+	if resp == nil {
+		return
+	}
+
+	span.SetAttributes(
+		Attribute{AttributeStatus, int(resp.Status)},
+		Attribute{AttributeIsRetryable, resp.IsRetryable},
+	)
+	if resp.Receipt.BundleId != "" {
+		span.SetAttributes(Attribute{AttributeBundleID, resp.Receipt.BundleId})
+	}
+}
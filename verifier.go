@@ -0,0 +1,68 @@
+package storekit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+
+	"github.com/pkg/errors"
+)
+
+// Verifier verifies receipts with the App Store. It is implemented by the
+// verification client and by the layers that can be stacked on top of it, such
+// as Deduplicator.
+//
+// Implementations return a non-nil result even when they return an error, and
+// are safe for concurrent use.
+type Verifier interface {
+	Verify(ctx context.Context, req *ReceiptRequest) (*VerifyResult, error)
+}
+
+// ErrNilRequest is returned by the verifiers of this package when given a nil
+// request.
+var ErrNilRequest = errors.New("nil receipt request")
+
+// VerifierFunc adapts an ordinary function to the Verifier interface.
+type VerifierFunc func(ctx context.Context, req *ReceiptRequest) (*VerifyResult, error)
+
+func (f VerifierFunc) Verify(ctx context.Context, req *ReceiptRequest) (*VerifyResult, error) {
+	return f(ctx, req)
+}
+
+// requestKey returns a digest identifying the receipt request, so that requests
+// for the same receipt, password and exclusion flag share a key. Secrets never
+// appear in the key.
+func requestKey(req *ReceiptRequest) string {
+	h := sha256.New()
+	writeField(h, req.ReceiptData)
+	writeField(h, []byte(req.Password))
+	if req.ExcludeOldTransactions {
+		h.Write([]byte{1})
+	} else {
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeField writes b length-prefixed, so that adjacent fields can't be
+// confused with each other.
+func writeField(h hash.Hash, b []byte) {
+	var n [8]byte
+	binary.BigEndian.PutUint64(n[:], uint64(len(b)))
+	h.Write(n[:])
+	h.Write(b)
+}
+
+var (
+	_ Verifier = (*Client)(nil)
+	_ Verifier = (*Deduplicator)(nil)
+	_ Verifier = (*CachingVerifier)(nil)
+	_ Verifier = (*Router)(nil)
+
+	_ sandboxPolicyChecker = (*Client)(nil)
+	_ sandboxPolicyChecker = (*Deduplicator)(nil)
+	_ sandboxPolicyChecker = (*CachingVerifier)(nil)
+	_ sandboxPolicyChecker = (*Router)(nil)
+)
@@ -0,0 +1,82 @@
+package storekit
+
+import (
+	"time"
+)
+
+// VerifyResult is the outcome of a Verify call. Besides the App Store response,
+// it records how the response was obtained, which helps auditing receipts that
+// bounced between the sandbox and production environments.
+type VerifyResult struct {
+	// Body is the raw bytes of the last App Store response, useful for storing,
+	// auditing, and for future verification checks. Nil if no response body was
+	// read.
+	Body []byte
+
+	// Response is Body parsed and mapped to a struct. Nil if no response could be
+	// decoded.
+	Response *ReceiptResponse
+
+	// URL is the verification URL that answered last.
+	URL string
+
+	// Environment is the environment of URL.
+	Environment Environment
+
+	// Sandbox is true if the receipt was verified in the sandbox environment.
+	// Sandbox purchases must never grant real entitlements.
+	Sandbox bool
+
+	// EnvironmentAutoFixed is true if the receipt was sent to the other
+	// environment after a 21007 or 21008 status.
+	EnvironmentAutoFixed bool
+
+	// SharedSecretIndex is the index, among the client's shared secrets, of the
+	// secret used for the final response. It is -1 if the client has no shared
+	// secrets or the request had a password of its own.
+	SharedSecretIndex int
+
+	// Attempts lists every request made to the App Store, in order, including
+	// retries and the environment auto fix.
+	Attempts []Attempt
+
+	// Shared is true if the result was obtained by a Deduplicator for several
+	// concurrent callers at once.
+	Shared bool
+
+	// Cached is true if the result was answered from a CachingVerifier's cache
+	// instead of the App Store.
+	Cached bool
+}
+
+// Attempt describes a single request made to the App Store server.
+type Attempt struct {
+	// Number is the position of the attempt in the Verify call, starting at 1.
+	Number int
+
+	// URL is the verification URL the request was sent to.
+	URL string
+
+	// Environment is the environment of URL.
+	Environment Environment
+
+	// Retry is true if the attempt repeats the previous one after a transient
+	// failure, as allowed by the retry policy. Attempts made for the environment
+	// auto fix or with the next shared secret are not retries.
+	Retry bool
+
+	// HTTPStatusCode is the HTTP status code of the response, or 0 if no response
+	// was received.
+	HTTPStatusCode int
+
+	// Status is the App Store status of the decoded response. Only meaningful if
+	// Err is nil or a *StatusError.
+	Status ReceiptResponseStatus
+
+	// Latency is the time taken to send the request and read and decode the
+	// response.
+	Latency time.Duration
+
+	// Err is the error of the attempt, if any.
+	Err error
+}